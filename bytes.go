@@ -0,0 +1,155 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+//WithBinaryText gives the byte slices holding valid UTF-8 on both sides the string diff of WithLineDiff and WithWordDiff,
+//instead of the binary diff
+func WithBinaryText() Option {
+	return func(o *options) {
+		o.binaryText = true
+	}
+}
+
+//bytesPerLine is the width of a hex dump line, and the gap under which two changed regions are joined
+const bytesPerLine = 16
+
+//binarySummary describes a byte slice without printing it
+type binarySummary struct {
+	Size int
+	Hash string // "sha256:" followed by the first 16 hex digits of the hash
+}
+
+func newBinarySummary(b []byte) binarySummary {
+	h := sha256.Sum256(b)
+	return binarySummary{Size: len(b), Hash: "sha256:" + hex.EncodeToString(h[:])[:16]}
+}
+
+func (s binarySummary) String() string {
+	return fmt.Sprintf("%d bytes %s", s.Size, s.Hash)
+}
+
+//binaryRegion is a range of bytes that differ at the same offset in both values.
+//Past the end of the shorter value, the region holds the bytes of the longer one only.
+type binaryRegion struct {
+	Offset   int
+	Current  []byte
+	Proposed []byte
+}
+
+//binaryDiff is the diff of the two values of a []byte Param
+type binaryDiff struct {
+	Current  binarySummary
+	Proposed binarySummary
+	Regions  []binaryRegion
+}
+
+func isBytes(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8
+}
+
+//isBinaryText reports whether c and p are byte slices to be compared as text
+func (df *differ) isBinaryText(c, p reflect.Value) bool {
+	return df.opts.binaryText && isBytes(c) && isBytes(p) && utf8.Valid(c.Bytes()) && utf8.Valid(p.Bytes())
+}
+
+//binaryDiff returns the diff of two byte slices, or nil if the values are not byte slices or are compared as text
+func (df *differ) binaryDiff(current, proposed reflect.Value) *binaryDiff {
+	if !isBytes(current) || !isBytes(proposed) || df.isBinaryText(current, proposed) {
+		return nil
+	}
+	c, p := current.Bytes(), proposed.Bytes()
+	return &binaryDiff{Current: newBinarySummary(c), Proposed: newBinarySummary(p), Regions: binaryRegions(c, p)}
+}
+
+//binaryRegions returns the changed regions of c and p, regions closer than bytesPerLine being joined
+func binaryRegions(c, p []byte) []binaryRegion {
+	var regions []binaryRegion
+	common := len(c)
+	if len(p) < common {
+		common = len(p)
+	}
+	start, end := -1, -1
+	flush := func() {
+		if start >= 0 {
+			regions = append(regions, binaryRegion{Offset: start, Current: c[start:end], Proposed: p[start:end]})
+		}
+	}
+	for i := 0; i < common; i++ {
+		if c[i] == p[i] {
+			continue
+		}
+		if start < 0 || i-end >= bytesPerLine {
+			flush()
+			start = i
+		}
+		end = i + 1
+	}
+	if len(c) != len(p) {
+		//the tail of the longer value extends the last region if close enough
+		if start < 0 || common-end >= bytesPerLine {
+			flush()
+			start = common
+		}
+		regions = append(regions, binaryRegion{Offset: start, Current: c[start:], Proposed: p[start:]})
+		return regions
+	}
+	flush()
+	return regions
+}
+
+//Hexdump returns the changed regions as hex dump lines of the current ("-") and proposed ("+") bytes.
+//Each line holds up to 16 bytes at the offset given in hex, followed by their printable characters.
+func (b binaryDiff) Hexdump() string {
+	var sb strings.Builder
+	for _, r := range b.Regions {
+		size := len(r.Current)
+		if len(r.Proposed) > size {
+			size = len(r.Proposed)
+		}
+		for o := 0; o < size; o += bytesPerLine {
+			hexLine(&sb, '-', r.Offset+o, window(r.Current, o))
+			hexLine(&sb, '+', r.Offset+o, window(r.Proposed, o))
+		}
+	}
+	return sb.String()
+}
+
+//window returns the line of at most bytesPerLine bytes of b starting at o
+func window(b []byte, o int) []byte {
+	if o >= len(b) {
+		return nil
+	}
+	if o+bytesPerLine > len(b) {
+		return b[o:]
+	}
+	return b[o : o+bytesPerLine]
+}
+
+func hexLine(sb *strings.Builder, op byte, offset int, line []byte) {
+	if len(line) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "%c%08x ", op, offset)
+	for i := 0; i < bytesPerLine; i++ {
+		if i < len(line) {
+			fmt.Fprintf(sb, " %02x", line[i])
+		} else {
+			sb.WriteString("   ")
+		}
+	}
+	sb.WriteString("  |")
+	for _, c := range line {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		sb.WriteByte(c)
+	}
+	sb.WriteString("|\n")
+}
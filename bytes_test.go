@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type blob struct {
+	N    string
+	Data []byte
+	Cert []byte `diff:"sensitive"`
+}
+
+func (b blob) ID() string {
+	return b.N
+}
+
+func TestBinaryRegions(t *testing.T) {
+	base := bytes.Repeat([]byte{0}, 40)
+	changed := func(b []byte, at ...int) []byte {
+		b = append([]byte(nil), b...)
+		for _, i := range at {
+			b[i] = 1
+		}
+		return b
+	}
+
+	testcase := []struct {
+		name     string
+		c, p     []byte
+		expected []binaryRegion
+	}{
+		{name: "equal", c: base, p: base},
+		{name: "one byte", c: base, p: changed(base, 3), expected: []binaryRegion{{Offset: 3, Current: []byte{0}, Proposed: []byte{1}}}},
+		{name: "close changes joined", c: base, p: changed(base, 3, 5), expected: []binaryRegion{{Offset: 3, Current: []byte{0, 0, 0}, Proposed: []byte{1, 0, 1}}}},
+		{name: "far changes", c: base, p: changed(base, 3, 30), expected: []binaryRegion{
+			{Offset: 3, Current: []byte{0}, Proposed: []byte{1}},
+			{Offset: 30, Current: []byte{0}, Proposed: []byte{1}},
+		}},
+		{name: "appended", c: base[:20], p: changed(base, 18)[:22], expected: []binaryRegion{{Offset: 18, Current: []byte{0, 0}, Proposed: []byte{1, 0, 0, 0}}}},
+		{name: "truncated", c: base, p: base[:4], expected: []binaryRegion{{Offset: 4, Current: base[4:], Proposed: []byte{}}}},
+	}
+	for _, test := range testcase {
+		regions := binaryRegions(test.c, test.p)
+		if !reflect.DeepEqual(regions, test.expected) {
+			t.Errorf("Test %s gave bad regions. Expected %v, got %v", test.name, test.expected, regions)
+		}
+	}
+}
+
+func TestCheckDiff2Binary(t *testing.T) {
+	current := blob{N: "B", Data: []byte("\x00\x01binary\xff"), Cert: []byte{1}}
+	proposed := blob{N: "B", Data: []byte("\x00\x02binary\xff!"), Cert: []byte{2}}
+	d, err := checkDiff2(current, proposed, WithBinaryText())
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	bd := d.Param["Data"].Binary
+	if bd == nil {
+		t.Fatalf("no binary diff on Data")
+	}
+	if bd.Current.String() != "9 bytes sha256:"+newBinarySummary(current.Data).Hash[7:] || bd.Proposed.Size != 10 {
+		t.Errorf("bad summaries %v %v", bd.Current, bd.Proposed)
+	}
+	expected := "-00000001  01 62 69 6e 61 72 79 ff" + strings.Repeat("   ", 8) + "  |.binary.|\n" +
+		"+00000001  02 62 69 6e 61 72 79 ff 21" + strings.Repeat("   ", 7) + "  |.binary.!|\n"
+	if h := bd.Hexdump(); h != expected {
+		t.Errorf("bad hex dump. Expected:\n%s\nGot:\n%s", expected, h)
+	}
+	if d.Param["Cert"].Binary != nil {
+		t.Errorf("binary diff of a sensitive field not redacted: %v", d.Param["Cert"].Binary)
+	}
+}
+
+func TestCheckDiff2BinaryText(t *testing.T) {
+	current, proposed := blob{N: "B", Data: []byte("key: a")}, blob{N: "B", Data: []byte("key: b")}
+
+	d, err := checkDiff2(current, proposed, WithBinaryText(), WithWordDiff(false))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	if d.Param["Data"].Binary != nil {
+		t.Errorf("unexpected binary diff on text: %v", d.Param["Data"].Binary)
+	}
+	expected := []textEdit{{textEqual, "key: "}, {textDelete, "a"}, {textInsert, "b"}}
+	if text := d.Param["Data"].Text; text == nil || !reflect.DeepEqual(text.Words, expected) {
+		t.Errorf("bad text diff. Expected %v, got %v", expected, text)
+	}
+
+	d, err = checkDiff2(current, proposed, WithWordDiff(false))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	if d.Param["Data"].Binary == nil || d.Param["Data"].Text != nil {
+		t.Errorf("expected a binary diff without WithBinaryText, got %v", d.Param["Data"])
+	}
+}
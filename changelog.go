@@ -0,0 +1,171 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+//ChangelogOption configures the Markdown changelog of a diff
+type ChangelogOption func(*changelogOptions)
+
+type changelogOptions struct {
+	tmpl *template.Template
+}
+
+//WithChangelogTemplate renders the changelog with t instead of DefaultChangelogTemplate.
+//t is executed on a changelog, see renderChangelog.
+func WithChangelogTemplate(t *template.Template) ChangelogOption {
+	return func(o *changelogOptions) {
+		o.tmpl = t
+	}
+}
+
+//DefaultChangelogTemplate is the text/template of the Markdown changelog, to be copied and adapted
+const DefaultChangelogTemplate = `## Changes to {{.ID}}
+{{if .Fields}}
+{{range .Fields}}{{template "field" .}}
+{{end}}{{end}}
+{{- range .Sections}}
+### {{.Name}}
+{{if .Added}}
+#### Added
+
+{{range .Added}}- ` + "`{{.ID}}`" + `
+{{end}}{{end}}
+{{- if .Removed}}
+#### Removed
+
+{{range .Removed}}- ` + "`{{.ID}}`" + `
+{{end}}{{end}}
+{{- if .Changed}}
+#### Changed
+
+{{range .Changed}}- ` + "`{{.ID}}`" + `{{with .Note}} {{.}}{{end}}
+{{range .Fields}}  {{template "field" .}}
+{{end}}{{end}}{{end}}
+{{- end}}
+{{- define "field"}}- {{if eq .Kind "added"}}added ` + "`{{.Path}}`" + `{{else if eq .Kind "removed"}}removed ` + "`{{.Path}}`" + `{{else}}` + "`{{.Path}}`" + `: {{.Current}} → {{.Proposed}}{{with .Delta}} ({{.}}){{end}}{{end}}{{end}}`
+
+var defaultChangelogTemplate = template.Must(template.New("changelog").Parse(DefaultChangelogTemplate))
+
+//changelog is the data given to the changelog template
+type changelog struct {
+	ID       string
+	Fields   []changelogField   // changes of the fields of the root
+	Sections []changelogSection // one per composition of the root, sorted by name
+}
+
+//changelogSection holds the changes of the items of a composition of the root
+type changelogSection struct {
+	Name    string
+	Added   []changelogEntry
+	Removed []changelogEntry
+	Changed []changelogEntry
+}
+
+//changelogEntry is an item of a composition
+type changelogEntry struct {
+	ID     string
+	Note   string           // renaming, type change or move of a changed item
+	Fields []changelogField // changes in a changed item, at any depth
+}
+
+//changelogField is a change found in an object, its path being relative to that object
+type changelogField struct {
+	Path     string
+	Kind     string // "changed" for a field, "added" or "removed" for a nested item
+	Current  string
+	Proposed string
+	Delta    string // numeric change with its percentage, if any
+}
+
+//renderChangelog writes d to w as a Markdown changelog: the changed fields of the root, then a section per
+//composition of the root listing its added, removed and changed items, with the changes of the latter.
+func renderChangelog(w io.Writer, d *diff, opts ...ChangelogOption) error {
+	o := changelogOptions{tmpl: defaultChangelogTemplate}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.tmpl.Execute(w, newChangelog(d))
+}
+
+func newChangelog(d *diff) changelog {
+	cl := changelog{ID: d.ID}
+	for _, name := range sortedKeys(d.Param) {
+		cl.Fields = append(cl.Fields, changelogFieldOf(name, d.Param[name]))
+	}
+	for _, name := range sortedCompositions(d) {
+		dc := d.Composition[name]
+		s := changelogSection{Name: name}
+		for _, item := range dc.New {
+			s.Added = append(s.Added, changelogEntry{ID: identifierOf(item)})
+		}
+		for _, item := range dc.Deleted {
+			s.Removed = append(s.Removed, changelogEntry{ID: identifierOf(item)})
+		}
+		for _, tc := range dc.TypeChanged {
+			s.Changed = append(s.Changed, changelogEntry{ID: tc.ID, Note: fmt.Sprintf("replaced by a %s", tc.ProposedType)})
+		}
+		for _, r := range dc.Renamed {
+			s.Changed = append(s.Changed, changelogEntry{ID: r.OldID, Note: "renamed to `" + r.NewID + "`", Fields: changelogFields(&r.Diff, "", nil)})
+		}
+		for i := range dc.Modified {
+			m := &dc.Modified[i]
+			s.Changed = append(s.Changed, changelogEntry{ID: m.ID, Fields: changelogFields(m, "", nil)})
+		}
+		cl.Sections = append(cl.Sections, s)
+	}
+	//moved items make a section of their own, as they leave a composition for another
+	var moved []changelogEntry
+	for _, m := range d.Moved {
+		e := changelogEntry{ID: m.ID, Note: "moved from `" + m.From + "` to `" + m.To + "`"}
+		if m.Diff != nil {
+			e.Fields = changelogFields(m.Diff, "", nil)
+		}
+		moved = append(moved, e)
+	}
+	if len(moved) > 0 {
+		cl.Sections = append(cl.Sections, changelogSection{Name: "Moved", Changed: moved})
+	}
+	return cl
+}
+
+//changelogFields appends to fields the changes of d at any depth, with paths relative to prefix
+func changelogFields(d *diff, prefix string, fields []changelogField) []changelogField {
+	for _, name := range sortedKeys(d.Param) {
+		fields = append(fields, changelogFieldOf(fieldPath(prefix, name), d.Param[name]))
+	}
+	for _, name := range sortedCompositions(d) {
+		dc := d.Composition[name]
+		fPath := fieldPath(prefix, name)
+		for _, item := range dc.New {
+			fields = append(fields, changelogField{Path: itemPath(fPath, identifierOf(item)), Kind: "added"})
+		}
+		for _, item := range dc.Deleted {
+			fields = append(fields, changelogField{Path: itemPath(fPath, identifierOf(item)), Kind: "removed"})
+		}
+		for _, tc := range dc.TypeChanged {
+			p := itemPath(fPath, tc.ID)
+			fields = append(fields, changelogField{Path: p, Kind: "changed", Current: tc.CurrentType.String(), Proposed: tc.ProposedType.String()})
+		}
+		for _, r := range dc.Renamed {
+			fields = append(fields, changelogField{Path: itemPath(fPath, r.OldID), Kind: "removed"}, changelogField{Path: itemPath(fPath, r.NewID), Kind: "added"})
+		}
+		for i := range dc.Modified {
+			fields = changelogFields(&dc.Modified[i], itemPath(fPath, dc.Modified[i].ID), fields)
+		}
+	}
+	return fields
+}
+
+func changelogFieldOf(path string, dv diffValues) changelogField {
+	f := changelogField{Path: path, Kind: "changed", Current: formatValue(dv.Current), Proposed: formatValue(dv.Proposed)}
+	if dv.Binary != nil {
+		f.Current, f.Proposed = dv.Binary.Current.String(), dv.Binary.Proposed.String()
+	}
+	if dv.Delta != nil {
+		f.Delta = dv.Delta.Format()
+	}
+	return f
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRenderChangelog(t *testing.T) {
+	current := deployment{N: "web", Replicas: 2, Image: "nginx:1.2", Pods: []taggedRecord{{Name: "a", Value: 1}, {Name: "b", Value: 1}}}
+	proposed := deployment{N: "web", Replicas: 3, Image: "nginx:1.2", Pods: []taggedRecord{{Name: "a", Value: 2}, {Name: "c", Value: 1}, {Name: "d"}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderChangelog(&sb, d); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := strings.Join([]string{
+		"## Changes to web",
+		"",
+		"- `Replicas`: 2 → 3 (+1, +50%)",
+		"",
+		"### Pods",
+		"",
+		"#### Added",
+		"",
+		"- `c`",
+		"- `d`",
+		"",
+		"#### Removed",
+		"",
+		"- `b`",
+		"",
+		"#### Changed",
+		"",
+		"- `a`",
+		"  - `Value`: 1 → 2 (+1, +100%)",
+		"",
+	}, "\n")
+	if sb.String() != expected {
+		t.Errorf("did not give expected changelog:\nExpected:\n%s\nGot:\n%s", expected, sb.String())
+	}
+}
+
+func TestRenderChangelogNested(t *testing.T) {
+	current := myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 1, F3: []myStruct{{P: "C1"}}}}}
+	proposed := myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 2, F3: []myStruct{{P: "C2"}}}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderChangelog(&sb, d); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := strings.Join([]string{
+		"## Changes to A",
+		"",
+		"### F3",
+		"",
+		"#### Changed",
+		"",
+		"- `B1`",
+		"  - `F1`: 1 → 2 (+1, +100%)",
+		"  - added `F3[C2]`",
+		"  - removed `F3[C1]`",
+		"",
+	}, "\n")
+	if sb.String() != expected {
+		t.Errorf("did not give expected changelog:\nExpected:\n%s\nGot:\n%s", expected, sb.String())
+	}
+}
+
+func TestRenderChangelogTemplate(t *testing.T) {
+	d, err := checkDiff2(deployment{N: "web"}, deployment{N: "web", Pods: []taggedRecord{{Name: "a"}}})
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	tmpl := template.Must(template.New("release").Parse(`Release of {{.ID}}:{{range .Sections}}{{range .Added}} new {{.ID}}{{end}}{{end}}`))
+	var sb strings.Builder
+	if err := renderChangelog(&sb, d, WithChangelogTemplate(tmpl)); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	if sb.String() != "Release of web: new a" {
+		t.Errorf("bad custom changelog %q", sb.String())
+	}
+}
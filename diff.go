@@ -1,29 +1,70 @@
 package api
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"reflect"
 )
 
 type diffValues struct {
-	Current  interface{}
-	Proposed interface{}
+	Current   interface{}
+	Proposed  interface{}
+	Immutable bool        // the field is tagged diff:"immutable"
+	Redacted  bool        // the values were replaced because the field is sensitive, see WithRedactedPaths
+	Violation string      // rule broken by the change, see validateDiff
+	Delta     *delta      // numeric change, for integers, floats and durations
+	Text      *textDiff   // line or word changes of strings, see WithLineDiff and WithWordDiff
+	Binary    *binaryDiff // size, hash and changed regions of byte slices
 }
 
 type diff struct {
 	ID          string
+	Path        string        // location of the object from the root of the run, empty for the root
+	Key         []interface{} // typed parts of the identifier, for objects identified by composite keys
 	Param       map[string]diffValues
 	Composition map[string]diffComposition
+	Moved       []moved // set on the root only, see WithMoveDetection
 }
 
 func (d *diff) Empty() bool {
-	return len(d.Composition) == 0 && len(d.Param) == 0
+	return len(d.Composition) == 0 && len(d.Param) == 0 && len(d.Moved) == 0
 }
 
 type diffComposition struct {
-	Modified []diff
-	Deleted  []interface{}
-	New      []interface{}
+	Modified    []diff
+	Deleted     []interface{}
+	New         []interface{}
+	TypeChanged []typeChange
+	Renamed     []renamed
+	Immutable   bool // the field is tagged diff:"immutable"
+	AppendOnly  bool // the field is tagged diff:"appendOnly"
+	CreateOnly  bool // the field is tagged diff:"createOnly" and was set in current
+	object      bool // the field holds a single object, found at the path of the field
+}
+
+//itemPath returns the path of a New or Deleted item of the composition at fPath
+func (dc diffComposition) itemPath(fPath string, item interface{}) string {
+	if dc.object {
+		return fPath
+	}
+	return itemPath(fPath, identifierOf(item))
+}
+
+//renamed records an object whose identifier changed while its content stayed similar
+type renamed struct {
+	OldID      string
+	NewID      string
+	Similarity float64
+	Diff       diff
+}
+
+//typeChange records an object replaced by an object of another concrete type with the same identifier
+type typeChange struct {
+	ID           string
+	CurrentType  reflect.Type
+	ProposedType reflect.Type
+	Current      interface{}
+	Proposed     interface{}
 }
 
 //HasIdentifier object implementing this interface are uniquely indentified by their path
@@ -37,250 +78,433 @@ var hasIdentifierType = reflect.TypeOf((*HasIdentifier)(nil)).Elem()
 //identifierFormInterface retrieve the HasIdentifier interface from a generic interface
 func identifierFormInterface(i interface{}) (HasIdentifier, error) {
 	if i == nil {
-		return nil, fmt.Errorf("nil interface cannot get identifier")
+		return nil, newDiffError("", ErrNilInput, "nil interface cannot get identifier")
 	}
 	iHasIdentifier, ok := i.(HasIdentifier)
 	if !ok {
-		return nil, fmt.Errorf("type assertion to 'hasIdentifier' failed")
+		return nil, newDiffError("", ErrNotIdentifiable, "type assertion to 'hasIdentifier' failed")
 	}
 	v := reflect.ValueOf(i)
 	switch v.Kind() {
 	case reflect.Interface, reflect.Ptr:
 		if v.IsNil() {
-			return nil, fmt.Errorf("nil pointed value")
+			return nil, newDiffError("", ErrNilInput, "nil pointed value")
 		}
 	}
 	return iHasIdentifier, nil
 }
 
-func checkDiff2(current, proposed HasIdentifier) (*diff, error) {
-	//Inputs validation
-	if current == nil || proposed == nil {
-		return nil, fmt.Errorf("Nil inputs")
+//concreteType returns the type of the object held by i, pointers being dereferenced so that T and *T are the same type
+func concreteType(i interface{}) reflect.Type {
+	if k, ok := i.(keyed); ok {
+		i = k.Value
 	}
-	if reflect.TypeOf(current).Name() != reflect.TypeOf(proposed).Name() {
-		return nil, fmt.Errorf("diff on object of different type")
+	return derefType(reflect.TypeOf(i))
+}
+
+//concreteValue returns the object held by i, pointers being dereferenced
+func concreteValue(i interface{}) reflect.Value {
+	if k, ok := i.(keyed); ok {
+		i = k.Value
 	}
-	if current.ID() != proposed.ID() {
-		return nil, fmt.Errorf("diff on object with different ID")
+	v := reflect.ValueOf(i)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
 	}
+	return v
+}
 
-	//Prepare output
-	d := diff{ID: current.ID(), Param: map[string]diffValues{}, Composition: map[string]diffComposition{}}
-
-	//Get the Value out of the inputs
-	vc := reflect.ValueOf(current)
-	vp := reflect.ValueOf(proposed)
-	if vc.Type().Kind() == reflect.Interface {
-		vc = vc.Elem()
-		vp = vp.Elem()
-	}
-	//Test in sequence with interface in case the interface value is a Ptr
-	if vc.Type().Kind() == reflect.Ptr {
-		vc = vc.Elem()
-		vp = vp.Elem()
-	}
-
-	for i := 0; i < vc.NumField(); i++ {
-		valueFieldc := vc.Field(i)
-		typeFieldc := vc.Type().Field(i)
-		tagc := typeFieldc.Tag
-		if tagc.Get("diff") == "ignore" {
-			//The field was tagged to be ignored in the diff process
-			continue
-		}
-		fieldName := typeFieldc.Name
-		valueFieldp := vp.FieldByName(fieldName)
+//typeName describes t with its package path, so that same-named types of different packages can be told apart
+func typeName(t reflect.Type) string {
+	if t.Name() == "" || t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
 
-		k := valueFieldc.Type().Kind()
-		switch {
-		case k >= reflect.Bool && k <= reflect.Complex128, k == reflect.String:
-			if !reflect.DeepEqual(valueFieldc.Interface(), valueFieldp.Interface()) {
-				d.Param[fieldName] = diffValues{Current: valueFieldc, Proposed: valueFieldp}
-			}
-		case k == reflect.Interface, k == reflect.Struct, k == reflect.Ptr:
-			if valueFieldc.Type().Implements(hasIdentifierType) {
+//differ holds the configuration and the running state of a diff run
+type differ struct {
+	ctx     context.Context
+	opts    options
+	changes int
+	errs    []error // errors collected when opts.collectErrors is set
+}
 
-				cID, cErr := identifierFormInterface(valueFieldc.Interface())
-				pID, pErr := identifierFormInterface(valueFieldp.Interface())
+func newDiffer(ctx context.Context, opts []Option) *differ {
+	return &differ{ctx: ctx, opts: newOptions(opts)}
+}
 
-				if cErr != nil && pErr != nil {
-					// both nil, not initialized
-					break
-				}
+//trial returns a differ for the diffs run aside the main one, to compare candidates or nested contents.
+//Its changes are not accounted in the limits nor its errors in the collected errors of the run.
+func (df *differ) trial() *differ {
+	trial := &differ{ctx: df.ctx, opts: df.opts}
+	trial.opts.maxChanges = 0
+	trial.opts.collectErrors = false
+	//the items of its diffs are redacted as they are found, moves being detected on the main run only
+	trial.opts.detectMoves = false
+	return trial
+}
 
-				//nil current and new proposed
-				if cErr != nil && pErr == nil {
-					dc := d.Composition[fieldName]
-					dc.New = append(dc.New, pID)
-					d.Composition[fieldName] = dc
-					break
-				}
+func checkDiff2(current, proposed HasIdentifier, opts ...Option) (*diff, error) {
+	return checkDiff2Context(context.Background(), current, proposed, opts...)
+}
 
-				//valid current and nil proposed
-				if cErr == nil && pErr != nil {
-					dc := d.Composition[fieldName]
-					dc.Deleted = append(dc.Deleted, cID)
-					d.Composition[fieldName] = dc
-					break
-				}
+//checkDiff2Context is checkDiff2 honoring the cancellation of ctx.
+//When a limit is exceeded or ctx is done, the diff computed so far is returned with the error.
+//With WithCollectErrors, the errors found along the way are joined and returned with the partial diff.
+func checkDiff2Context(ctx context.Context, current, proposed HasIdentifier, opts ...Option) (*diff, error) {
+	df := newDiffer(ctx, opts)
+	d, err := df.diff("", current, proposed, 0)
+	if d != nil && df.opts.detectMoves {
+		df.detectMoves(d)
+	}
+	return df.result(d, err)
+}
 
-				//two valid values. Need to compare
-				if cErr == nil && pErr == nil {
-					//same identifier need to compare content
-					if cID.ID() == pID.ID() {
-						md, err := checkDiff2(cID, pID)
-						if err != nil {
-							return nil, err
-						}
-						if !md.Empty() {
-							dc := d.Composition[fieldName]
-							dc.Modified = append(dc.Modified, *md)
-							d.Composition[fieldName] = dc
-						}
-					} else { //the object was replaced by another one
-						dc := d.Composition[fieldName]
-						dc.Deleted = append(dc.Deleted, cID)
-						dc.New = append(dc.New, pID)
-						d.Composition[fieldName] = dc
-					}
-				}
-			} else {
-				if !reflect.DeepEqual(valueFieldc.Interface(), valueFieldp.Interface()) {
-					d.Param[fieldName] = diffValues{Current: valueFieldc, Proposed: valueFieldp}
-				}
+//result joins the errors collected during the run to err
+func (df *differ) result(d *diff, err error) (*diff, error) {
+	if len(df.errs) == 0 {
+		return d, err
+	}
+	return d, errors.Join(append(df.errs, err)...)
+}
+
+//handle records err and returns nil when the run collects errors and err does not interrupt it.
+//Otherwise err is returned for the caller to abort.
+func (df *differ) handle(err error) error {
+	if !df.opts.collectErrors || isInterruption(err) {
+		return err
+	}
+	df.errs = append(df.errs, err)
+	return nil
+}
+
+//stop returns the partial diff with err if err interrupted the run, and no diff otherwise
+func stop(d *diff, err error) (*diff, error) {
+	if isInterruption(err) {
+		return d, err
+	}
+	return nil, err
+}
+
+//addParam records the change of a field. Its values are redacted if they hold sensitive fields.
+func (df *differ) addParam(d *diff, fieldName, path string, current, proposed reflect.Value) error {
+	if err := df.countChange(path); err != nil {
+		return err
+	}
+	d.Param[fieldName] = diffValues{
+		Current:  df.redactObject(path, current.Interface()),
+		Proposed: df.redactObject(path, proposed.Interface()),
+		Delta:    newDelta(current, proposed),
+		Text:     df.textDiff(current, proposed),
+		Binary:   df.binaryDiff(current, proposed),
+	}
+	return nil
+}
+
+//redactItem returns the New or Deleted item at path redacted, unless moves are to be detected on the original items.
+//detectMoves then redacts the items.
+func (df *differ) redactItem(path string, item interface{}) interface{} {
+	if df.opts.detectMoves {
+		return item
+	}
+	return df.redactObject(path, item)
+}
+
+//addNew records a new item, path being the path of the item
+func (df *differ) addNew(d *diff, fieldName, path string, item interface{}) error {
+	if err := df.countChange(path); err != nil {
+		return err
+	}
+	dc := d.Composition[fieldName]
+	dc.object = path == fieldPath(d.Path, fieldName)
+	dc.New = append(dc.New, df.redactItem(path, item))
+	d.Composition[fieldName] = dc
+	return nil
+}
+
+//addDeleted records a deleted item, path being the path of the item
+func (df *differ) addDeleted(d *diff, fieldName, path string, item interface{}) error {
+	if err := df.countChange(path); err != nil {
+		return err
+	}
+	dc := d.Composition[fieldName]
+	dc.object = path == fieldPath(d.Path, fieldName)
+	dc.Deleted = append(dc.Deleted, df.redactItem(path, item))
+	d.Composition[fieldName] = dc
+	return nil
+}
+
+func (df *differ) addTypeChanged(d *diff, fieldName, path string, current, proposed HasIdentifier) error {
+	if err := df.countChange(path); err != nil {
+		return err
+	}
+	dc := d.Composition[fieldName]
+	dc.TypeChanged = append(dc.TypeChanged, typeChange{
+		ID:           current.ID(),
+		CurrentType:  concreteType(current),
+		ProposedType: concreteType(proposed),
+		Current:      df.redactObject(path, current),
+		Proposed:     df.redactObject(path, proposed),
+	})
+	d.Composition[fieldName] = dc
+	return nil
+}
+
+//addModified recurses into two objects with the same identifier and records their diff if not empty
+func (df *differ) addModified(d *diff, fieldName, path string, current, proposed HasIdentifier, depth int) error {
+	md, err := df.diff(path, current, proposed, depth+1)
+	if md != nil && !md.Empty() {
+		dc := d.Composition[fieldName]
+		dc.Modified = append(dc.Modified, *md)
+		d.Composition[fieldName] = dc
+	}
+	return err
+}
+
+//diff compares two objects sharing the same identifier. path locates them from the root of the run.
+func (df *differ) diff(path string, current, proposed HasIdentifier, depth int) (*diff, error) {
+	//Inputs validation
+	//typed nil pointers are nil inputs as well, their ID method cannot be called
+	for _, input := range []HasIdentifier{current, proposed} {
+		if _, err := identifierFormInterface(input); err != nil {
+			return nil, newDiffError(path, ErrNilInput, "Nil inputs")
+		}
+	}
+	if ct, pt := concreteType(current), concreteType(proposed); ct != pt {
+		return nil, newDiffError(path, ErrTypeMismatch, "diff on object of different type: %s vs %s", typeName(ct), typeName(pt))
+	}
+	if current.ID() != proposed.ID() {
+		return nil, newDiffError(path, ErrIDMismatch, "diff on object with different ID")
+	}
+	return df.content(path, current, proposed, depth)
+}
+
+//content compares the fields of two objects of the same type, whatever their identifiers
+func (df *differ) content(path string, current, proposed HasIdentifier, depth int) (*diff, error) {
+	if err := df.ctx.Err(); err != nil {
+		return nil, &DiffError{Path: path, Err: err}
+	}
+	if err := df.checkDepth(path, depth); err != nil {
+		return nil, err
+	}
+
+	//Prepare output
+	d := diff{ID: current.ID(), Path: path, Param: map[string]diffValues{}, Composition: map[string]diffComposition{}}
+	if k, ok := current.(keyed); ok {
+		d.Key = k.Parts
+	}
+
+	//Get the Value out of the inputs, each side can be given by value or by pointer
+	vc := concreteValue(current)
+	vp := concreteValue(proposed)
+
+	//Objects without fields are compared as a whole, the change being recorded under their type name
+	if vc.Kind() != reflect.Struct {
+		if !df.equal(vc, vp) {
+			if err := df.addParam(&d, vc.Type().Name(), path, vc, vp); err != nil {
+				return stop(&d, err)
 			}
-		case k == reflect.Array || k == reflect.Slice:
-			// check if inner type implements HasIdentifier
-			if valueFieldc.Type().Elem().Implements(hasIdentifierType) {
-				same, added, deleted, err := checkDiffInComposition(valueFieldc.Interface(), valueFieldp.Interface())
-				if err != nil {
-					return nil, err
-				}
-				for _, n := range added {
-					dc := d.Composition[fieldName]
-					dc.New = append(dc.New, n)
-					d.Composition[fieldName] = dc
-				}
-				for _, n := range deleted {
-					dc := d.Composition[fieldName]
-					dc.Deleted = append(dc.Deleted, n)
-					d.Composition[fieldName] = dc
-				}
-				for _, n := range same {
-					md, err := checkDiff2(n[0], n[1])
-					if err != nil {
-						return nil, err
-					}
-					if !md.Empty() {
-						dc := d.Composition[fieldName]
-						dc.Modified = append(dc.Modified, *md)
-						d.Composition[fieldName] = dc
-					}
-				}
-			} else {
-				if !reflect.DeepEqual(valueFieldc.Interface(), valueFieldp.Interface()) {
-					d.Param[fieldName] = diffValues{Current: valueFieldc, Proposed: valueFieldp}
-				}
+		}
+		return &d, nil
+	}
+
+	info := structInfoOf(vc.Type())
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if fi.ignore {
+			//The field was tagged to be ignored in the diff process
+			continue
+		}
+		if fi.err == nil && !vc.Type().Field(fi.index).IsExported() {
+			//Unexported fields cannot be read, they only serve as identifiers
+			continue
+		}
+		fPath := fieldPath(path, fi.name)
+		err := fi.err
+		if err == nil {
+			err = df.diffField(&d, fi, fPath, vc.Field(fi.index), vp.Field(fi.index), depth)
+			if err == nil {
+				err = df.checkRules(&d, fi, fPath, vc.Field(fi.index))
+			}
+			df.redactParam(&d, fi, fPath)
+			fi.flag(&d)
+		} else {
+			err = &DiffError{Path: fPath, Err: err}
+		}
+		if err != nil {
+			if err = df.handle(err); err != nil {
+				return stop(&d, err)
 			}
 		}
 	}
 	return &d, nil
 }
 
-func checkDiff(current, proposed HasIdentifier) (*diff, error) {
-	if current == nil || proposed == nil {
-		return nil, fmt.Errorf("Nil inputs")
+//compareValue records a change of the field if its two values are not equal once normalized.
+//The change holds the original values.
+func (df *differ) compareValue(d *diff, fi *fieldInfo, fPath string, valueFieldc, valueFieldp reflect.Value) error {
+	nc, np, err := df.normalize(fi, fPath, valueFieldc, valueFieldp)
+	if err != nil {
+		return err
 	}
-
-	if current.ID() != proposed.ID() {
-		return nil, fmt.Errorf("diff on bucket not under same path")
+	if df.equalValues(fi, nc, np) {
+		return nil
 	}
+	return df.addParam(d, fi.name, fPath, valueFieldc, valueFieldp)
+}
 
-	d := diff{ID: current.ID(), Param: map[string]diffValues{}, Composition: map[string]diffComposition{}}
+//diffField records in d the changes between the two values of the field.
+//The field is compared as a value or as a composition of identified objects depending on its type,
+//unless it is tagged diff:"value" or diff:"composition".
+func (df *differ) diffField(d *diff, fi *fieldInfo, fPath string, valueFieldc, valueFieldp reflect.Value, depth int) error {
+	fieldName := fi.name
+	if fi.value {
+		return df.compareValue(d, fi, fPath, valueFieldc, valueFieldp)
+	}
+	composition := fi.composition
 
-	vc := reflect.ValueOf(current)
-	vp := reflect.ValueOf(proposed)
-	for i := 0; i < vc.NumField(); i++ {
+	k := valueFieldc.Type().Kind()
+	switch {
+	case k >= reflect.Bool && k <= reflect.Complex128, k == reflect.String:
+		if composition {
+			return newDiffError(fPath, ErrNotIdentifiable, "field tagged composition of type %s", valueFieldc.Type())
+		}
+		return df.compareValue(d, fi, fPath, valueFieldc, valueFieldp)
+	case k == reflect.Interface, k == reflect.Struct, k == reflect.Ptr:
+		if !valueFieldc.Type().Implements(hasIdentifierType) {
+			if composition {
+				return newDiffError(fPath, ErrNotIdentifiable, "field tagged composition of type %s", valueFieldc.Type())
+			}
+			return df.compareValue(d, fi, fPath, valueFieldc, valueFieldp)
+		}
 
-		vcurrent := vc.Field(i).Interface()
-		vproposed := vp.Field(i).Interface()
+		//a nil pointer and a pointer to a zero object are equal if the options say so
+		if k == reflect.Ptr && df.opts.nilEqualsZero && valueFieldc.IsNil() != valueFieldp.IsNil() && df.equal(valueFieldc, valueFieldp) {
+			return nil
+		}
 
-		tag := vc.Type().Field(i).Tag
-		fieldName := vc.Type().Field(i).Name
+		cID, cErr := identifierFormInterface(valueFieldc.Interface())
+		pID, pErr := identifierFormInterface(valueFieldp.Interface())
 
-		switch tag.Get("diff") {
-		case "value":
-			if !reflect.DeepEqual(vcurrent, vproposed) {
-				d.Param[fieldName] = diffValues{Current: vcurrent, Proposed: vproposed}
+		switch {
+		case cErr != nil && pErr != nil:
+			// both nil, not initialized
+			return nil
+		case cErr != nil:
+			//nil current and new proposed
+			return df.addNew(d, fieldName, fPath, pID)
+		case pErr != nil:
+			//valid current and nil proposed
+			return df.addDeleted(d, fieldName, fPath, cID)
+		case cID.ID() == pID.ID() && concreteType(cID) != concreteType(pID):
+			//same identifier held by an object of another type
+			return df.addTypeChanged(d, fieldName, fPath, cID, pID)
+		case cID.ID() == pID.ID():
+			//same identifier need to compare content
+			return df.addModified(d, fieldName, fPath, cID, pID, depth)
+		default:
+			//the object was replaced by another one, unless it was renamed
+			if _, _, renames := df.pairRenames(fPath, []HasIdentifier{pID}, []HasIdentifier{cID}, depth); len(renames) == 1 {
+				return df.addRenamed(d, fieldName, fPath, renames[0])
 			}
-		case "composition":
-			same, added, deleted, err := checkDiffInComposition(vcurrent, vproposed)
-			if err != nil {
-				return nil, err
+			if err := df.addDeleted(d, fieldName, fPath, cID); err != nil {
+				return err
 			}
-			for _, n := range added {
-				dc := d.Composition[fieldName]
-				dc.New = append(dc.New, n)
-				d.Composition[fieldName] = dc
+			return df.addNew(d, fieldName, fPath, pID)
+		}
+	case k == reflect.Map:
+		if composition {
+			return newDiffError(fPath, ErrNotIdentifiable, "field tagged composition of type %s", valueFieldc.Type())
+		}
+		return df.compareValue(d, fi, fPath, valueFieldc, valueFieldp)
+	case k == reflect.Array || k == reflect.Slice:
+		//nil and empty slices hold the same items, they differ only if the options say so
+		if k == reflect.Slice && nilAndEmpty(valueFieldc, valueFieldp) {
+			if df.opts.nilDistinctFromEmpty {
+				return df.addParam(d, fieldName, fPath, valueFieldc, valueFieldp)
 			}
-			for _, n := range deleted {
-				dc := d.Composition[fieldName]
-				dc.Deleted = append(dc.Deleted, n)
-				d.Composition[fieldName] = dc
+			return nil
+		}
+		// check if inner type can be identified,
+		// a composition tag lets the items fail to be identified one by one
+		keys := fi.keys
+		if !composition && !df.identifiable(valueFieldc.Type().Elem(), keys) {
+			if df.matchable(valueFieldc.Type().Elem()) {
+				return df.matchComposition(d, fieldName, fPath, valueFieldc, valueFieldp, depth)
 			}
-			for _, n := range same {
-				fmt.Printf("Checking compo under same path %s\n", n[0].ID())
-				md, err := checkDiff(n[0], n[1])
-				if err != nil {
-					return nil, err
-				}
-				if !md.Empty() {
-					fmt.Println("Diff detected in composition")
-					dc := d.Composition[fieldName]
-					dc.Modified = append(dc.Modified, *md)
-					d.Composition[fieldName] = dc
+			return df.compareValue(d, fi, fPath, valueFieldc, valueFieldp)
+		}
+		same, added, deleted, err := df.composition(fPath, keys, valueFieldc.Interface(), valueFieldp.Interface())
+		if err != nil {
+			return err
+		}
+		added, deleted, retyped := pairTypeChanges(added, deleted)
+		for _, n := range retyped {
+			if err := df.addTypeChanged(d, fieldName, itemPath(fPath, n[0].ID()), n[0], n[1]); err != nil {
+				return err
+			}
+		}
+		added, deleted, renames := df.pairRenames(fPath, added, deleted, depth)
+		for _, r := range renames {
+			if err := df.addRenamed(d, fieldName, itemPath(fPath, r.OldID), r); err != nil {
+				return err
+			}
+		}
+		for _, n := range added {
+			if err := df.addNew(d, fieldName, itemPath(fPath, n.ID()), n); err != nil {
+				return err
+			}
+		}
+		for _, n := range deleted {
+			if err := df.addDeleted(d, fieldName, itemPath(fPath, n.ID()), n); err != nil {
+				return err
+			}
+		}
+		for _, n := range same {
+			if err := df.addModified(d, fieldName, itemPath(fPath, n[0].ID()), n[0], n[1], depth); err != nil {
+				if err = df.handle(err); err != nil {
+					return err
 				}
 			}
 		}
+	}
+	return nil
+}
+
+func checkDiffInComposition(current, proposed interface{}, opts ...Option) (samePath [][2]HasIdentifier, newPath, deletedPath []HasIdentifier, err error) {
+	return checkDiffInCompositionContext(context.Background(), current, proposed, opts...)
+}
 
+//checkDiffInCompositionContext is checkDiffInComposition honoring the cancellation of ctx
+func checkDiffInCompositionContext(ctx context.Context, current, proposed interface{}, opts ...Option) (samePath [][2]HasIdentifier, newPath, deletedPath []HasIdentifier, err error) {
+	df := newDiffer(ctx, opts)
+	samePath, newPath, deletedPath, err = df.composition("", nil, current, proposed)
+	if len(df.errs) > 0 {
+		err = errors.Join(append(df.errs, err)...)
 	}
-	return &d, nil
+	return
 }
 
-func checkDiffInComposition(current, proposed interface{}) (samePath [][2]HasIdentifier, newPath, deletedPath []HasIdentifier, err error) {
+//composition matches the items of two slices of identifiable objects. path locates the composition from the root of the run.
+//keys names the fields identifying the items, if empty they are identified as described by identify.
+func (df *differ) composition(path string, keys []string, current, proposed interface{}) (samePath [][2]HasIdentifier, newPath, deletedPath []HasIdentifier, err error) {
 	samePath = [][2]HasIdentifier{}
 	newPath = []HasIdentifier{}
 	deletedPath = []HasIdentifier{}
 
-	// index all path in current composition
-	currentMap := map[string]HasIdentifier{}
-	s := reflect.ValueOf(current)
-	for i := 0; i < s.Len(); i++ {
-		item := s.Index(i)
-		p, ok := item.Interface().(HasIdentifier)
-		if !ok {
-			err = fmt.Errorf("Compisition of non-PathIdentifier in current: %T", item.Interface())
-			return
-		}
-		currentMap[p.ID()] = p
+	// index all path in current and proposed composition
+	currentMap, currentKeys, err := df.indexComposition(path, keys, "current", current)
+	if err != nil {
+		return
 	}
-
-	// index all path in proposed composition
-	proposedMap := map[string]HasIdentifier{}
-	s = reflect.ValueOf(proposed)
-	for i := 0; i < s.Len(); i++ {
-		item := s.Index(i)
-		p, ok := item.Interface().(HasIdentifier)
-		if !ok {
-			err = fmt.Errorf("Compisition of non-PathIdentifier in proposed: %T", item.Interface())
-			return
-		}
-		proposedMap[p.ID()] = p
+	proposedMap, proposedKeys, err := df.indexComposition(path, keys, "proposed", proposed)
+	if err != nil {
+		return
 	}
 
 	//Deleted and Same
-	for k := range currentMap {
+	for _, k := range currentKeys {
 		if p, ok := proposedMap[k]; ok {
 			samePath = append(samePath, ([2]HasIdentifier{currentMap[k], p}))
 		} else {
@@ -289,7 +513,7 @@ func checkDiffInComposition(current, proposed interface{}) (samePath [][2]HasIde
 	}
 
 	//New
-	for k := range proposedMap {
+	for _, k := range proposedKeys {
 		if _, ok := currentMap[k]; !ok {
 			newPath = append(newPath, proposedMap[k])
 		}
@@ -297,3 +521,76 @@ func checkDiffInComposition(current, proposed interface{}) (samePath [][2]HasIde
 
 	return samePath, newPath, deletedPath, nil
 }
+
+//pairTypeChanges extracts from the new and deleted items of a composition the pairs sharing the same identifier.
+//Those are objects whose concrete type changed.
+func pairTypeChanges(added, deleted []HasIdentifier) (remainingAdded, remainingDeleted []HasIdentifier, retyped [][2]HasIdentifier) {
+	addedByID := map[string]int{}
+	for i, n := range added {
+		if _, ok := addedByID[n.ID()]; !ok {
+			addedByID[n.ID()] = i
+		}
+	}
+	paired := map[int]bool{}
+	for _, o := range deleted {
+		if i, ok := addedByID[o.ID()]; ok && !paired[i] && concreteType(o) != concreteType(added[i]) {
+			paired[i] = true
+			retyped = append(retyped, [2]HasIdentifier{o, added[i]})
+			continue
+		}
+		remainingDeleted = append(remainingDeleted, o)
+	}
+	for i, n := range added {
+		if !paired[i] {
+			remainingAdded = append(remainingAdded, n)
+		}
+	}
+	return remainingAdded, remainingDeleted, retyped
+}
+
+//compositionKey identifies an item of a composition. The concrete type is part of the key
+//so that objects of different types sharing an identifier can live in the same slice.
+type compositionKey struct {
+	t   reflect.Type
+	key interface{}
+}
+
+//indexComposition indexes the items of the slice v by key, keys are returned in the slice order
+func (df *differ) indexComposition(path string, keyNames []string, side string, v interface{}) (map[compositionKey]HasIdentifier, []compositionKey, error) {
+	index := map[compositionKey]HasIdentifier{}
+	keys := []compositionKey{}
+	s := reflect.ValueOf(v)
+	if err := df.checkCompositionSize(path, s.Len()); err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < s.Len(); i++ {
+		if err := df.ctx.Err(); err != nil {
+			return nil, nil, &DiffError{Path: path, Err: err}
+		}
+		item := s.Index(i)
+		p, ok := df.identify(item, keyNames)
+		if !ok {
+			if err := df.handle(newDiffError(path, ErrNotIdentifiable, "Compisition of non-PathIdentifier in %s: %T", side, item.Interface())); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		key, ok := typedKey(p)
+		if !ok {
+			if err := df.handle(newDiffError(itemPath(path, p.ID()), ErrNotIdentifiable, "key of %s is not comparable", p.ID())); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		k := compositionKey{t: concreteType(p), key: key}
+		if _, dup := index[k]; dup {
+			if err := df.handle(newDiffError(itemPath(path, p.ID()), ErrDuplicateID, "duplicate identifier in %s: %s", side, p.ID())); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		index[k] = p
+		keys = append(keys, k)
+	}
+	return index, keys, nil
+}
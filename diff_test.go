@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -41,6 +42,14 @@ func (p myStruct) ID() string {
 }
 
 func diffReport(d *diff, report []string) []string {
+	for _, m := range d.Moved {
+		line := fmt.Sprintf("%s:Moved=%s->%s", m.ID, m.From, m.To)
+		report = append(report, line)
+		if m.Diff != nil {
+			report = diffReport(m.Diff, report)
+		}
+	}
+
 	for k, v := range d.Param {
 		line := fmt.Sprintf("%s.%s:%v->%v", d.ID, k, v.Current, v.Proposed)
 		report = append(report, line)
@@ -61,6 +70,15 @@ func diffReport(d *diff, report []string) []string {
 				report = append(report, line)
 			}
 		}
+		for _, tc := range v.TypeChanged {
+			line := fmt.Sprintf("%s.%s:TypeChanged=%s(%s->%s)", d.ID, k, tc.ID, tc.CurrentType.Name(), tc.ProposedType.Name())
+			report = append(report, line)
+		}
+		for _, r := range v.Renamed {
+			line := fmt.Sprintf("%s.%s:Renamed=%s->%s", d.ID, k, r.OldID, r.NewID)
+			report = append(report, line)
+			report = diffReport(&r.Diff, report)
+		}
 		if v.Modified != nil {
 			for _, dd := range v.Modified {
 				line := fmt.Sprintf("%s.%s:Modified=%s", d.ID, k, dd.ID)
@@ -206,6 +224,30 @@ func TestCheckDiff2(t *testing.T) {
 			proposed: myStruct{P: "A", F11: &innerStruct{A: "AA"}},
 			report:   []string{"A.F11:New=AA"},
 		},
+		{
+			name:     "interfaceTypeChanged",
+			current:  myStruct{P: "A", F8: innerStruct{A: "X"}},
+			proposed: myStruct{P: "A", F8: myStruct{P: "X"}},
+			report:   []string{"A.F8:TypeChanged=X(innerStruct->myStruct)"},
+		},
+		{
+			name:     "interfaceSameTypeModified",
+			current:  myStruct{P: "A", F8: &innerStruct{A: "X", Data: "d0"}},
+			proposed: myStruct{P: "A", F8: innerStruct{A: "X", Data: "d1"}},
+			report:   []string{"A.F8:Modified=X", "X.Data:d0->d1"},
+		},
+		{
+			name:     "mixedCompositionTypeChanged",
+			current:  myStruct{P: "A", F5: []HasIdentifier{innerStruct{A: "X"}, PathI("Y")}},
+			proposed: myStruct{P: "A", F5: []HasIdentifier{myStruct{P: "X"}, PathI("Y")}},
+			report:   []string{"A.F5:TypeChanged=X(innerStruct->myStruct)"},
+		},
+		{
+			name:     "mixedCompositionSameIDDifferentTypes",
+			current:  myStruct{P: "A", F5: []HasIdentifier{innerStruct{A: "X"}, PathI("X")}},
+			proposed: myStruct{P: "A", F5: []HasIdentifier{PathI("X"), innerStruct{A: "X", Data: "d1"}}},
+			report:   []string{"A.F5:Modified=X", "X.Data:->d1"},
+		},
 		{
 			name:     "anyStructChange",
 			current:  myStruct{P: "A"},
@@ -232,110 +274,69 @@ func TestCheckDiff2(t *testing.T) {
 	}
 }
 
-func TestCheckDiff(t *testing.T) {
+type tagStruct struct {
+	P      string
+	Ident  innerStruct `diff:"value"`
+	Items  []PathI     `diff:"value"`
+	Forced []PathI     `diff:"composition"`
+	Bad    []string    `diff:"composition"`
+}
+
+func (p tagStruct) ID() string {
+	return p.P
+}
+
+func TestCheckDiff2Tags(t *testing.T) {
 
 	testcase := []struct {
-		name     string
-		current  myStruct
-		proposed myStruct
-		report   []string
+		name        string
+		current     tagStruct
+		proposed    tagStruct
+		report      []string
+		expectedErr error
 	}{
 		{
-			name:     "no_changes_f4nil",
-			current:  myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{}},
-			proposed: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{}},
-			report:   []string{},
-		},
-		{
-			name:     "no_changes_f3f4nil",
-			current:  myStruct{P: "A", F1: 1, F2: 2},
-			proposed: myStruct{P: "A", F1: 1, F2: 2},
-			report:   []string{},
-		},
-		{
-			name:     "f1change_f4nil",
-			current:  myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{}},
-			proposed: myStruct{P: "A", F1: 10, F2: 2, F3: []myStruct{}},
-			report:   []string{"A.F1:1->10"},
-		},
-		{
-			name:     "f1f2change",
-			current:  myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{}},
-			proposed: myStruct{P: "A", F1: 10, F2: 20, F3: []myStruct{}},
-			report:   []string{"A.F1:1->10"},
-		},
-		{
-			name:     "f3nilandnotnilchange",
-			current:  myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{}},
-			proposed: myStruct{P: "A", F1: 1, F2: 2},
-			report:   []string{},
-		},
-		{
-			name:     "f3New_x2_f4nil",
-			current:  myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{}},
-			proposed: myStruct{P: "A", F1: 1, F2: 2},
-			report:   []string{},
-		},
-		{
-			name:    "f3New_x2",
-			current: myStruct{P: "A", F1: 1, F2: 2},
-			proposed: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B1", F1: 1, F2: 2}, {P: "B2", F1: 10, F2: 20},
-			}},
-			report: []string{"A.F3:New=B1", "A.F3:New=B2"},
+			name:     "value on identified struct",
+			current:  tagStruct{P: "A", Ident: innerStruct{A: "X", Data: "d0"}},
+			proposed: tagStruct{P: "A", Ident: innerStruct{A: "X", Data: "d1"}},
+			report:   []string{"A.Ident:{X d0}->{X d1}"},
 		},
 		{
-			name: "f3NewAndDelete",
-			current: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B1", F1: 1, F2: 2},
-			}},
-			proposed: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B2", F1: 10, F2: 20},
-			}},
-			report: []string{"A.F3:Deleted=B1", "A.F3:New=B2"},
+			name:     "value on identified slice",
+			current:  tagStruct{P: "A", Items: []PathI{"X"}},
+			proposed: tagStruct{P: "A", Items: []PathI{"X", "Y"}},
+			report:   []string{"A.Items:[X]->[X Y]"},
 		},
 		{
-			name: "f3.B1_modified",
-			current: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B1", F1: 1, F2: 2}, {P: "B2", F1: 10, F2: 20},
-			}},
-			proposed: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B1", F1: 3, F2: 3}, {P: "B2", F1: 10, F2: 20},
-			}},
-			report: []string{"A.F3:Modified=B1", "B1.F1:1->3"},
+			name:     "composition",
+			current:  tagStruct{P: "A", Forced: []PathI{"X"}},
+			proposed: tagStruct{P: "A", Forced: []PathI{"Y"}},
+			report:   []string{"A.Forced:Deleted=X", "A.Forced:New=Y"},
 		},
 		{
-			name: "doubleCompoAllTypeOfChange",
-			current: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B1", F1: 1, F2: 2, F3: []myStruct{
-					{P: "C1", F1: 3, F2: 3}, {P: "C2", F1: 10, F2: 20},
-				},
-				},
-			}},
-			proposed: myStruct{P: "A", F1: 1, F2: 2, F3: []myStruct{
-				{P: "B1", F1: 5, F2: 2, F3: []myStruct{
-					{P: "C3", F1: 0, F2: 0}, {P: "C2", F1: 100, F2: 20},
-				},
-				},
-			}},
-			report: []string{"A.F3:Modified=B1", "B1.F1:1->5", "B1.F3:Deleted=C1", "B1.F3:Modified=C2", "B1.F3:New=C3", "C2.F1:10->100"},
+			name:        "composition of non identifiable",
+			current:     tagStruct{P: "A", Bad: []string{"X"}},
+			proposed:    tagStruct{P: "A"},
+			expectedErr: ErrNotIdentifiable,
 		},
 	}
 
 	for _, test := range testcase {
-		fmt.Printf("Test %s\n", test.name)
-		d, err := checkDiff(test.current, test.proposed)
+		d, err := checkDiff2(test.current, test.proposed)
+		if test.expectedErr != nil {
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("Test %s, expected error %v, got %v", test.name, test.expectedErr, err)
+			}
+			continue
+		}
 		if err != nil {
 			t.Errorf("Test %s failed with error %v", test.name, err)
 			continue
 		}
-		report := []string{}
-		report = diffReport(d, report)
+		report := diffReport(d, []string{})
 		sort.Strings(report)
-		sort.Strings(test.report)
 		if !reflect.DeepEqual(report, test.report) {
 			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
-			continue
 		}
 	}
 }
@@ -539,3 +540,25 @@ func TestIdentifierFormInterface(t *testing.T) {
 
 	}
 }
+
+func TestCheckDiff2TypeIdentity(t *testing.T) {
+
+	d, err := checkDiff2(myStruct{P: "A", F1: 1}, &myStruct{P: "A", F1: 2})
+	if err != nil {
+		t.Fatalf("value and pointer forms of the same type failed with error %v", err)
+	}
+	report := diffReport(d, []string{})
+	if !reflect.DeepEqual(report, []string{"A.F1:1->2"}) {
+		t.Errorf("value and pointer forms, bad report %v", report)
+	}
+
+	_, err = checkDiff2(&testStruct{}, testStructV{})
+	if err == nil {
+		t.Fatalf("different types did not fail")
+	}
+	pkg := reflect.TypeOf(testStruct{}).PkgPath()
+	expected := fmt.Sprintf("diff on object of different type: %s.testStruct vs %s.testStructV", pkg, pkg)
+	if err.Error() != expected {
+		t.Errorf("bad type mismatch error.\nExpected: %s\nGot: %s", expected, err.Error())
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"math"
+	"reflect"
+)
+
+//WithNilEqualsEmpty sets whether a nil slice or map equals an empty one. It applies to the fields compared as values,
+//at any depth, and to compositions. They are equal by default.
+func WithNilEqualsEmpty(equal bool) Option {
+	return func(o *options) {
+		o.nilDistinctFromEmpty = !equal
+	}
+}
+
+//WithNilEqualsZero sets whether a nil pointer equals a pointer to the zero value of its type.
+//It applies to the fields compared as values, at any depth, and to identified objects held by pointer.
+//They differ by default.
+func WithNilEqualsZero(equal bool) Option {
+	return func(o *options) {
+		o.nilEqualsZero = equal
+	}
+}
+
+//nilAndEmpty reports whether one of the slices or maps c and p is nil and the other is empty but not nil
+func nilAndEmpty(c, p reflect.Value) bool {
+	return c.IsNil() != p.IsNil() && c.Len() == 0 && p.Len() == 0
+}
+
+//equal compares two values of the same type like reflect.DeepEqual, nil and empty values and numbers being compared as set by the options.
+//The values of a type given to WithNormalizer are compared once normalized.
+func (df *differ) equal(c, p reflect.Value) bool {
+	return df.deepEqual(c, p, df.opts.tolerance, map[[2]uintptr]bool{})
+}
+
+func (df *differ) deepEqual(c, p reflect.Value, tol tolerance, visited map[[2]uintptr]bool) bool {
+	if !c.IsValid() || !p.IsValid() {
+		return c.IsValid() == p.IsValid()
+	}
+	if c.Type() != p.Type() {
+		return false
+	}
+	//unexported fields cannot be handed to a normalizer
+	if fn, ok := df.opts.typeNormalizers[c.Type()]; ok && c.CanInterface() && p.CanInterface() {
+		c, p = applyNormalizer(fn, c), applyNormalizer(fn, p)
+		if !c.IsValid() || !p.IsValid() {
+			return c.IsValid() == p.IsValid()
+		}
+		if c.Type() != p.Type() {
+			return false
+		}
+	}
+	return df.deepEqualValues(c, p, tol, visited)
+}
+
+//deepEqualValues compares two valid values of the same type, their elements being compared by deepEqual
+func (df *differ) deepEqualValues(c, p reflect.Value, tol tolerance, visited map[[2]uintptr]bool) bool {
+	switch c.Kind() {
+	case reflect.Slice, reflect.Map:
+		if c.IsNil() != p.IsNil() {
+			if df.opts.nilDistinctFromEmpty || c.Len() != 0 || p.Len() != 0 {
+				return false
+			}
+			return true
+		}
+		if c.Len() != p.Len() {
+			return false
+		}
+		if c.Kind() == reflect.Map {
+			iter := c.MapRange()
+			for iter.Next() {
+				pv := p.MapIndex(iter.Key())
+				if !pv.IsValid() || !df.deepEqual(iter.Value(), pv, tol, visited) {
+					return false
+				}
+			}
+			return true
+		}
+		for i := 0; i < c.Len(); i++ {
+			if !df.deepEqual(c.Index(i), p.Index(i), tol, visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < c.Len(); i++ {
+			if !df.deepEqual(c.Index(i), p.Index(i), tol, visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr:
+		if c.IsNil() || p.IsNil() {
+			if c.IsNil() && p.IsNil() {
+				return true
+			}
+			if !df.opts.nilEqualsZero {
+				return false
+			}
+			if c.IsNil() {
+				return p.Elem().IsZero()
+			}
+			return c.Elem().IsZero()
+		}
+		key := [2]uintptr{c.Pointer(), p.Pointer()}
+		if key[0] == key[1] || visited[key] {
+			return true
+		}
+		visited[key] = true
+		return df.deepEqual(c.Elem(), p.Elem(), tol, visited)
+	case reflect.Interface:
+		if c.IsNil() || p.IsNil() {
+			return c.IsNil() == p.IsNil()
+		}
+		return df.deepEqual(c.Elem(), p.Elem(), tol, visited)
+	case reflect.Struct:
+		for i := 0; i < c.NumField(); i++ {
+			if !df.deepEqual(c.Field(i), p.Field(i), tol, visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Func:
+		//as reflect.DeepEqual, functions are equal only if both nil
+		return c.IsNil() && p.IsNil()
+	case reflect.Bool:
+		return c.Bool() == p.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return c.Int() == p.Int() || !tol.exact() && tol.within(float64(c.Int()), float64(p.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return c.Uint() == p.Uint() || !tol.exact() && tol.within(float64(c.Uint()), float64(p.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return tol.within(c.Float(), p.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return c.Complex() == p.Complex()
+	case reflect.String:
+		return c.String() == p.String()
+	case reflect.Chan, reflect.UnsafePointer:
+		return c.Pointer() == p.Pointer()
+	}
+	return false
+}
+
+//tolerance is the difference accepted between two numbers before they are reported as changed
+type tolerance struct {
+	abs float64 // absolute difference
+	rel float64 // difference relative to the largest magnitude of the two numbers
+}
+
+//exact reports whether the tolerance accepts no difference. Integers are then compared as integers,
+//as float64 cannot tell apart the integers above 2^53.
+func (tol tolerance) exact() bool {
+	return tol.abs == 0 && tol.rel == 0
+}
+
+//within reports whether a and b are equal within the tolerance. NaN equals NaN.
+func (tol tolerance) within(a, b float64) bool {
+	if a == b || (math.IsNaN(a) && math.IsNaN(b)) {
+		return true
+	}
+	d := math.Abs(a - b)
+	if d <= tol.abs {
+		return true
+	}
+	return d <= tol.rel*math.Max(math.Abs(a), math.Abs(b))
+}
+
+//WithTolerance sets the absolute and relative difference accepted between two numbers, at any depth, before
+//they are reported as changed: they are equal if |a-b| <= abs or |a-b| <= rel*max(|a|,|b|).
+//A diff:"tolerance=x" tag sets the absolute tolerance of a field, and overrides this option.
+func WithTolerance(abs, rel float64) Option {
+	return func(o *options) {
+		o.tolerance = tolerance{abs: abs, rel: rel}
+	}
+}
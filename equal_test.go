@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type emptyHolder struct {
+	N      string
+	Labels map[string]string
+	Tags   []string `diff:"value"`
+	Opt    *plainRecord
+	Nested []plainRecord
+}
+
+func (h emptyHolder) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2NilAndEmpty(t *testing.T) {
+
+	testcase := []struct {
+		name     string
+		current  HasIdentifier
+		proposed HasIdentifier
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "nil and empty slice are equal by default",
+			current:  myStruct{P: "A", F6: nil, F3: nil},
+			proposed: myStruct{P: "A", F6: []int{}, F3: []myStruct{}},
+			report:   []string{},
+		},
+		{
+			name:     "nil and empty slice differ",
+			current:  myStruct{P: "A", F6: nil, F3: nil},
+			proposed: myStruct{P: "A", F6: []int{}, F3: []myStruct{}},
+			opts:     []Option{WithNilEqualsEmpty(false)},
+			report:   []string{"A.F3:[]->[]", "A.F6:[]->[]"},
+		},
+		{
+			name:     "nil and empty map are equal by default",
+			current:  emptyHolder{N: "H", Tags: []string{}},
+			proposed: emptyHolder{N: "H", Labels: map[string]string{}},
+			report:   []string{},
+		},
+		{
+			name:     "nil and empty map differ",
+			current:  emptyHolder{N: "H", Tags: []string{}, Nested: []plainRecord{}},
+			proposed: emptyHolder{N: "H", Labels: map[string]string{}},
+			opts:     []Option{WithNilEqualsEmpty(false)},
+			report:   []string{"H.Labels:map[]->map[]", "H.Nested:[]->[]", "H.Tags:[]->[]"},
+		},
+		{
+			name:     "map change",
+			current:  emptyHolder{N: "H", Labels: map[string]string{"a": "1"}},
+			proposed: emptyHolder{N: "H", Labels: map[string]string{"a": "2"}},
+			report:   []string{"H.Labels:map[a:1]->map[a:2]"},
+		},
+		{
+			name:     "nil pointer and pointer to zero differ by default",
+			current:  emptyHolder{N: "H"},
+			proposed: emptyHolder{N: "H", Opt: &plainRecord{}},
+			report:   []string{"H.Opt:<nil>->&{ 0}"},
+		},
+		{
+			name:     "nil pointer equals pointer to zero",
+			current:  emptyHolder{N: "H"},
+			proposed: emptyHolder{N: "H", Opt: &plainRecord{}},
+			opts:     []Option{WithNilEqualsZero(true)},
+			report:   []string{},
+		},
+		{
+			name:     "nil identified pointer equals pointer to zero",
+			current:  myStruct{P: "A"},
+			proposed: myStruct{P: "A", F11: &innerStruct{}},
+			opts:     []Option{WithNilEqualsZero(true)},
+			report:   []string{},
+		},
+		{
+			name:     "pointer to non zero is still new",
+			current:  emptyHolder{N: "H"},
+			proposed: emptyHolder{N: "H", Opt: &plainRecord{Value: 1}},
+			opts:     []Option{WithNilEqualsZero(true)},
+			report:   []string{"H.Opt:<nil>->&{ 1}"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, test.opts...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+type metrics struct {
+	N       string
+	Load    float64
+	Ratio   float64 `diff:"tolerance=0.01"`
+	Count   int
+	Samples []float64
+}
+
+func (m metrics) ID() string {
+	return m.N
+}
+
+func TestCheckDiff2Tolerance(t *testing.T) {
+	nan := math.NaN()
+	tenth, fifth := 0.1, 0.2
+
+	testcase := []struct {
+		name     string
+		current  metrics
+		proposed metrics
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "exact by default",
+			current:  metrics{N: "M", Load: tenth + fifth},
+			proposed: metrics{N: "M", Load: 0.3},
+			report:   []string{"M.Load:0.30000000000000004->0.3"},
+		},
+		{
+			name:     "absolute tolerance",
+			current:  metrics{N: "M", Load: tenth + fifth, Samples: []float64{1, 2.0001}},
+			proposed: metrics{N: "M", Load: 0.3, Samples: []float64{1, 2}},
+			opts:     []Option{WithTolerance(0.001, 0)},
+			report:   []string{},
+		},
+		{
+			name:     "relative tolerance",
+			current:  metrics{N: "M", Load: 1000, Count: 100},
+			proposed: metrics{N: "M", Load: 1001, Count: 102},
+			opts:     []Option{WithTolerance(0, 0.01)},
+			report:   []string{"M.Count:100->102"},
+		},
+		{
+			name:     "tolerance tag",
+			current:  metrics{N: "M", Ratio: 0.5, Load: 1},
+			proposed: metrics{N: "M", Ratio: 0.505, Load: 1.001},
+			report:   []string{"M.Load:1->1.001"},
+		},
+		{
+			name:     "tolerance tag overrides option",
+			current:  metrics{N: "M", Ratio: 0.5},
+			proposed: metrics{N: "M", Ratio: 0.52},
+			opts:     []Option{WithTolerance(0.1, 0)},
+			report:   []string{"M.Ratio:0.5->0.52"},
+		},
+		{
+			name:     "NaN equals NaN",
+			current:  metrics{N: "M", Load: nan, Samples: []float64{nan}},
+			proposed: metrics{N: "M", Load: nan, Samples: []float64{nan}},
+			report:   []string{},
+		},
+		{
+			name:     "NaN differs from a number",
+			current:  metrics{N: "M", Load: nan},
+			proposed: metrics{N: "M", Load: 1},
+			opts:     []Option{WithTolerance(1, 1)},
+			report:   []string{"M.Load:NaN->1"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, test.opts...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+type badToleranceStruct struct {
+	P    string
+	Load float64 `diff:"tolerance=-1"`
+}
+
+func (b badToleranceStruct) ID() string {
+	return b.P
+}
+
+func TestCheckDiff2BadTolerance(t *testing.T) {
+	_, err := checkDiff2(badToleranceStruct{P: "A"}, badToleranceStruct{P: "A"})
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected ErrInvalidTag, got %v", err)
+	}
+}
+
+type counters struct {
+	N    string
+	Big  int64
+	Huge uint64
+	Ids  []int64
+}
+
+func (c counters) ID() string {
+	return c.N
+}
+
+func TestCheckDiff2LargeIntegers(t *testing.T) {
+	//these integers are equal once converted to float64
+	current := counters{N: "C", Big: 1<<53 + 1, Huge: 1<<63 + 1, Ids: []int64{1<<53 + 1}}
+	proposed := counters{N: "C", Big: 1 << 53, Huge: 1 << 63, Ids: []int64{1 << 53}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	report := diffReport(d, []string{})
+	sort.Strings(report)
+	expected := []string{"C.Big:9007199254740993->9007199254740992", "C.Huge:9223372036854775809->9223372036854775808", "C.Ids:[9007199254740993]->[9007199254740992]"}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("did not give expected report:\nExpected:\n%v\nGot:\n%v\n", expected, report)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Sentinel errors wrapped by DiffError and ViolationError, to be tested with errors.Is
+var (
+	ErrNilInput        = errors.New("nil input")
+	ErrTypeMismatch    = errors.New("type mismatch")
+	ErrIDMismatch      = errors.New("identifier mismatch")
+	ErrNotIdentifiable = errors.New("not identifiable")
+	ErrDuplicateID     = errors.New("duplicate identifier")
+	ErrInvalidTag      = errors.New("invalid diff tag")
+	ErrInvalidOption   = errors.New("invalid option")
+	ErrPolicyViolation = errors.New("change policy violation")
+)
+
+//DiffError locates a failure of the diff process.
+//Path is relative to the diffed object: "F3[B1].F10" is field F10 of the item B1 of composition F3.
+type DiffError struct {
+	Path string
+	Err  error
+	Msg  string
+}
+
+func newDiffError(path string, err error, format string, args ...interface{}) *DiffError {
+	return &DiffError{Path: path, Err: err, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *DiffError) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+	if e.Path == "" {
+		return msg
+	}
+	return e.Path + ": " + msg
+}
+
+func (e *DiffError) Unwrap() error {
+	return e.Err
+}
+
+//fieldPath returns the path of a field of the object at path
+func fieldPath(path, fieldName string) string {
+	if path == "" {
+		return fieldName
+	}
+	return path + "." + fieldName
+}
+
+//itemPath returns the path of the item identified by id in the composition at path
+func itemPath(path, id string) string {
+	return path + "[" + id + "]"
+}
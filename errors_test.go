@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCheckDiff2Errors(t *testing.T) {
+
+	testcase := []struct {
+		name         string
+		current      HasIdentifier
+		proposed     HasIdentifier
+		expectedErr  error
+		expectedPath string
+	}{
+		{
+			name:        "nil input",
+			current:     myStruct{P: "A"},
+			expectedErr: ErrNilInput,
+		},
+		{
+			name:        "typed nil input",
+			current:     (*myStruct)(nil),
+			proposed:    &myStruct{P: "A"},
+			expectedErr: ErrNilInput,
+		},
+		{
+			name:        "type mismatch",
+			current:     myStruct{P: "A"},
+			proposed:    innerStruct{A: "A"},
+			expectedErr: ErrTypeMismatch,
+		},
+		{
+			name:        "id mismatch",
+			current:     myStruct{P: "A"},
+			proposed:    myStruct{P: "B"},
+			expectedErr: ErrIDMismatch,
+		},
+		{
+			name:         "duplicate id",
+			current:      myStruct{P: "A", F3: []myStruct{{P: "B1"}}},
+			proposed:     myStruct{P: "A", F3: []myStruct{{P: "B1", F3: []myStruct{{P: "C1"}, {P: "C1"}}}}},
+			expectedErr:  ErrDuplicateID,
+			expectedPath: "F3[B1].F3[C1]",
+		},
+	}
+
+	for _, test := range testcase {
+		_, err := checkDiff2(test.current, test.proposed)
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("Test %s, expected error %v, got %v", test.name, test.expectedErr, err)
+			continue
+		}
+		var de *DiffError
+		if !errors.As(err, &de) {
+			t.Errorf("Test %s, expected a DiffError, got %T", test.name, err)
+			continue
+		}
+		if de.Path != test.expectedPath {
+			t.Errorf("Test %s, bad path. Expected %q, got %q", test.name, test.expectedPath, de.Path)
+		}
+	}
+}
+
+func TestCheckDiffInCompositionNotIdentifiable(t *testing.T) {
+	_, _, _, err := checkDiffInComposition([]string{"toto"}, []string{})
+	if !errors.Is(err, ErrNotIdentifiable) {
+		t.Errorf("expected ErrNotIdentifiable, got %v", err)
+	}
+}
+
+func TestCheckDiff2CollectErrors(t *testing.T) {
+	current := myStruct{P: "A", F1: 1, F5: []HasIdentifier{PathI("X")}, F3: []myStruct{{P: "B1"}, {P: "B2", F1: 1}}}
+	proposed := myStruct{P: "A", F1: 2, F5: []HasIdentifier{PathI("X"), PathI("X")}, F3: []myStruct{{P: "B1", F3: []myStruct{{P: "C1"}, {P: "C1"}}}, {P: "B2", F1: 2}}}
+
+	if _, err := checkDiff2(current, proposed); err == nil {
+		t.Fatalf("expected failure without WithCollectErrors")
+	}
+
+	d, err := checkDiff2(current, proposed, WithCollectErrors())
+	if d == nil {
+		t.Fatalf("expected a partial diff, got error %v", err)
+	}
+	if !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("expected joined ErrDuplicateID, got %v", err)
+	}
+	for _, path := range []string{"F3[B1].F3[C1]", "F5[X]"} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("expected an error at %s, got %v", path, err)
+		}
+	}
+
+	report := diffReport(d, []string{})
+	sort.Strings(report)
+	expected := []string{"A.F1:1->2", "A.F3:Modified=B1", "A.F3:Modified=B2", "B1.F3:New=C1", "B2.F1:1->2"}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("did not give expected report:\nExpected:\n%v\nGot:\n%v\n", expected, report)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"reflect"
+)
+
+//HTMLOption configures the HTML report of a diff
+type HTMLOption func(*htmlOptions)
+
+type htmlOptions struct {
+	title    string
+	expanded bool
+}
+
+//WithHTMLTitle sets the title of the HTML report, "Diff of <ID>" by default
+func WithHTMLTitle(title string) HTMLOption {
+	return func(o *htmlOptions) {
+		o.title = title
+	}
+}
+
+//WithHTMLExpanded opens all the nodes of the HTML report, they are collapsed below the root by default
+func WithHTMLExpanded() HTMLOption {
+	return func(o *htmlOptions) {
+		o.expanded = true
+	}
+}
+
+//htmlNode is an object or an item of the HTML report
+type htmlNode struct {
+	Label    string
+	Kind     string // "modified", "new", "deleted", "type", "renamed" or "moved"
+	Note     string
+	Open     bool
+	Params   []htmlParam
+	Content  []string // lines of the whole new or deleted item
+	Children []htmlNode
+}
+
+//htmlParam is a changed field of the HTML report, with its values side by side
+type htmlParam struct {
+	Name     string
+	Current  string
+	Proposed string
+	Note     string // delta and violation
+	Lines    []htmlLine
+}
+
+//htmlLine is a row of the side by side line diff of a string field
+type htmlLine struct {
+	Current  string
+	Proposed string
+	Changed  bool
+}
+
+//renderHTML writes d to w as a self-contained HTML page: a collapsible tree of the changed objects,
+//their changed fields side by side, and the full content of new and deleted items.
+func renderHTML(w io.Writer, d *diff, opts ...HTMLOption) error {
+	o := htmlOptions{title: "Diff of " + d.ID}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r := &htmlReport{opts: o, df: newDiffer(context.Background(), nil)}
+	root := r.node(d, d.ID, "modified", "")
+	root.Open = true
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		Empty bool
+		Root  htmlNode
+	}{o.title, d.Empty(), root})
+}
+
+type htmlReport struct {
+	opts htmlOptions
+	df   *differ
+}
+
+//node builds the report node of the object d
+func (r *htmlReport) node(d *diff, label, kind, note string) htmlNode {
+	n := htmlNode{Label: label, Kind: kind, Note: note, Open: r.opts.expanded}
+	for _, m := range d.Moved {
+		c := htmlNode{Label: m.ID, Kind: "moved", Note: m.From + " -> " + m.To, Open: r.opts.expanded}
+		if m.Diff != nil {
+			c = r.node(m.Diff, m.ID, "moved", m.From+" -> "+m.To)
+		}
+		n.Children = append(n.Children, c)
+	}
+	for _, name := range sortedKeys(d.Param) {
+		n.Params = append(n.Params, htmlParamOf(name, d.Param[name]))
+	}
+	for _, name := range sortedCompositions(d) {
+		dc := d.Composition[name]
+		for _, item := range dc.Deleted {
+			n.Children = append(n.Children, r.item(itemPath(name, identifierOf(item)), "deleted", item))
+		}
+		for _, item := range dc.New {
+			n.Children = append(n.Children, r.item(itemPath(name, identifierOf(item)), "new", item))
+		}
+		for _, tc := range dc.TypeChanged {
+			note := fmt.Sprintf("type %s -> %s", tc.CurrentType, tc.ProposedType)
+			c := htmlNode{Label: itemPath(name, tc.ID), Kind: "type", Note: note, Open: r.opts.expanded}
+			c.Children = []htmlNode{r.item("current", "deleted", tc.Current), r.item("proposed", "new", tc.Proposed)}
+			n.Children = append(n.Children, c)
+		}
+		for _, rn := range dc.Renamed {
+			note := fmt.Sprintf("renamed %s (%.0f%%)", rn.NewID, rn.Similarity*100)
+			n.Children = append(n.Children, r.node(&rn.Diff, itemPath(name, rn.OldID), "renamed", note))
+		}
+		for i := range dc.Modified {
+			n.Children = append(n.Children, r.node(&dc.Modified[i], itemPath(name, dc.Modified[i].ID), "modified", ""))
+		}
+	}
+	return n
+}
+
+//item builds the report node of a new or deleted item, holding its full content
+func (r *htmlReport) item(label, kind string, item interface{}) htmlNode {
+	if k, ok := item.(keyed); ok {
+		item = k.Value
+	}
+	n := htmlNode{Label: label, Kind: kind, Open: r.opts.expanded}
+	for _, l := range r.df.serialize(reflect.ValueOf(item), false) {
+		n.Content = append(n.Content, l.text)
+	}
+	return n
+}
+
+func htmlParamOf(name string, dv diffValues) htmlParam {
+	p := htmlParam{Name: name, Current: formatValue(dv.Current), Proposed: formatValue(dv.Proposed)}
+	if dv.Binary != nil {
+		p.Current, p.Proposed = dv.Binary.Current.String(), dv.Binary.Proposed.String()
+	}
+	if dv.Delta != nil {
+		p.Note = dv.Delta.Format()
+	}
+	if dv.Violation != "" {
+		if p.Note != "" {
+			p.Note += ", "
+		}
+		p.Note += dv.Violation
+	}
+	if dv.Text != nil && len(dv.Text.Lines) > 0 {
+		p.Lines = sideBySide(dv.Text.Lines)
+	}
+	return p
+}
+
+//sideBySide pairs the deleted and inserted lines of each change of a line diff in rows
+func sideBySide(edits []textEdit) []htmlLine {
+	var rows []htmlLine
+	var deleted, inserted []string
+	flush := func() {
+		for i := 0; i < len(deleted) || i < len(inserted); i++ {
+			row := htmlLine{Changed: true}
+			if i < len(deleted) {
+				row.Current = deleted[i]
+			}
+			if i < len(inserted) {
+				row.Proposed = inserted[i]
+			}
+			rows = append(rows, row)
+		}
+		deleted, inserted = nil, nil
+	}
+	for _, e := range edits {
+		switch e.Op {
+		case textDelete:
+			deleted = append(deleted, e.Text)
+		case textInsert:
+			inserted = append(inserted, e.Text)
+		default:
+			flush()
+			rows = append(rows, htmlLine{Current: e.Text, Proposed: e.Text})
+		}
+	}
+	flush()
+	return rows
+}
+
+var htmlTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em; }
+details { margin-left: 1.2em; border-left: 1px solid #ddd; padding-left: .5em; }
+summary { cursor: pointer; padding: 2px 0; }
+.label { font-family: monospace; font-weight: bold; }
+.kind { font-size: 11px; text-transform: uppercase; border-radius: 3px; padding: 0 4px; margin-right: 4px; color: #fff; }
+.modified > summary .kind, .renamed > summary .kind, .moved > summary .kind, .type > summary .kind { background: #b08800; }
+.new > summary .kind { background: #22863a; }
+.deleted > summary .kind { background: #cb2431; }
+.note { color: #666; margin-left: .5em; }
+table { border-collapse: collapse; margin: 4px 0; }
+td, th { border: 1px solid #ddd; padding: 2px 6px; font-family: monospace; vertical-align: top; white-space: pre-wrap; }
+th { background: #f6f8fa; font-family: sans-serif; }
+.old { background: #ffeef0; }
+.cur { background: #e6ffed; }
+pre { margin: 4px 0; padding: 4px; }
+.new > pre { background: #e6ffed; }
+.deleted > pre { background: #ffeef0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Empty}}<p>No changes.</p>{{else}}{{template "node" .Root}}{{end}}
+</body>
+</html>
+{{define "node"}}<details class="{{.Kind}}"{{if .Open}} open{{end}}>
+<summary><span class="kind">{{.Kind}}</span><span class="label">{{.Label}}</span>{{with .Note}}<span class="note">{{.}}</span>{{end}}</summary>
+{{if .Params}}<table>
+<tr><th>Field</th><th>Current</th><th>Proposed</th><th></th></tr>
+{{range .Params}}{{if .Lines}}<tr><td>{{.Name}}</td><td colspan="3"><table>
+{{range .Lines}}<tr><td{{if .Changed}} class="old"{{end}}>{{.Current}}</td><td{{if .Changed}} class="cur"{{end}}>{{.Proposed}}</td></tr>
+{{end}}</table></td></tr>
+{{else}}<tr><td>{{.Name}}</td><td class="old">{{.Current}}</td><td class="cur">{{.Proposed}}</td><td>{{.Note}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}{{if .Content}}<pre>{{range .Content}}{{.}}
+{{end}}</pre>
+{{end}}{{range .Children}}{{template "node" .}}{{end}}</details>
+{{end}}`))
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	current := deployment{N: "web", Replicas: 2, Image: "<nginx>", Pods: []taggedRecord{{Name: "a", Value: 1}, {Name: "b", Value: 1}}}
+	proposed := deployment{N: "web", Replicas: 3, Image: "<nginx>", Pods: []taggedRecord{{Name: "a", Value: 2}, {Name: "c", Value: 1}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderHTML(&sb, d, WithHTMLTitle("Deploy <web>")); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	page := sb.String()
+
+	for _, fragment := range []string{
+		"<title>Deploy &lt;web&gt;</title>",
+		`<details class="modified" open>`,
+		`<span class="label">web</span>`,
+		`<tr><td>Replicas</td><td class="old">2</td><td class="cur">3</td><td>&#43;1, &#43;50%</td></tr>`,
+		`<details class="modified">` + "\n" + `<summary><span class="kind">modified</span><span class="label">Pods[a]</span>`,
+		`<tr><td>Value</td><td class="old">1</td><td class="cur">2</td><td>&#43;1, &#43;100%</td></tr>`,
+		`<span class="label">Pods[b]</span></summary>` + "\n" + "<pre>Name: b\nValue: 1\n</pre>",
+		`<details class="new">`,
+		`<span class="label">Pods[c]</span>`,
+	} {
+		if !strings.Contains(page, fragment) {
+			t.Errorf("page does not hold %q:\n%s", fragment, page)
+		}
+	}
+	//the page is self-contained
+	for _, external := range []string{"src=", "href=", "<script", "<link"} {
+		if strings.Contains(page, external) {
+			t.Errorf("page refers to external assets with %q", external)
+		}
+	}
+	if strings.Count(page, "<details") != strings.Count(page, "</details>") {
+		t.Errorf("unbalanced details in page:\n%s", page)
+	}
+}
+
+func TestRenderHTMLLines(t *testing.T) {
+	d, err := checkDiff2(script{N: "S", Body: "a\nb\nc"}, script{N: "S", Body: "a\nx\ny\nc"}, WithLineDiff(1))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderHTML(&sb, d, WithHTMLExpanded()); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := "<tr><td>a</td><td>a</td></tr>\n" +
+		`<tr><td class="old">b</td><td class="cur">x</td></tr>` + "\n" +
+		`<tr><td class="old"></td><td class="cur">y</td></tr>` + "\n" +
+		"<tr><td>c</td><td>c</td></tr>\n"
+	if !strings.Contains(sb.String(), expected) {
+		t.Errorf("page does not hold the side by side lines %q:\n%s", expected, sb.String())
+	}
+
+	sb.Reset()
+	if err := renderHTML(&sb, &diff{ID: "S"}); err != nil || !strings.Contains(sb.String(), "No changes.") {
+		t.Errorf("expected a page without changes, got %v:\n%s", err, sb.String())
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//keyed gives an identifier to an object that does not implement HasIdentifier.
+//It is found in place of the object in the New and Deleted items of a composition.
+//Parts holds the typed components of the key, Key their display form.
+//Positional is set when the key is the index of the object in its slice, which does not follow the object.
+type keyed struct {
+	Key        string
+	Parts      []interface{}
+	Value      interface{}
+	Positional bool
+}
+
+func newKeyed(value interface{}, parts []interface{}) keyed {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = fmt.Sprint(p)
+	}
+	return keyed{Key: strings.Join(s, "/"), Parts: parts, Value: value}
+}
+
+func (k keyed) ID() string {
+	return k.Key
+}
+
+//WithKeyFunc identifies the objects of type t with fn, for types that cannot implement HasIdentifier.
+//t can be given in value or pointer form, fn always receives the object as a value of type t, not a pointer.
+func WithKeyFunc(t reflect.Type, fn func(interface{}) string) Option {
+	return WithKeyPartsFunc(t, func(i interface{}) []interface{} {
+		return []interface{}{fn(i)}
+	})
+}
+
+//WithKeyPartsFunc identifies the objects of type t with a composite key made of the comparable values returned by fn.
+//Two objects have the same key if all their parts are equal, with the same types.
+//As for WithKeyFunc, fn receives the object as a value whether the composition holds values or pointers.
+func WithKeyPartsFunc(t reflect.Type, fn func(interface{}) []interface{}) Option {
+	return func(o *options) {
+		if o.keyFuncs == nil {
+			o.keyFuncs = map[reflect.Type]func(interface{}) []interface{}{}
+		}
+		o.keyFuncs[derefType(t)] = fn
+	}
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+//idFields returns the indexes of the fields of the struct type t tagged diff:"id"
+func idFields(t reflect.Type) []int {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return structInfoOf(t).ids
+}
+
+//identifiable reports whether the elements of type t of a composition can be identified.
+//keys are the fields named by a diff:"key=..." tag on the composition, if any.
+func (df *differ) identifiable(t reflect.Type, keys []string) bool {
+	if len(keys) > 0 || t.Implements(hasIdentifierType) {
+		return true
+	}
+	if _, ok := df.opts.keyFuncs[derefType(t)]; ok {
+		return true
+	}
+	return len(idFields(t)) > 0
+}
+
+//identify returns the identified form of a composition element. In order of precedence the identifier
+//comes from the key fields of the element, a key function registered for its type, its ID method or its fields tagged diff:"id".
+func (df *differ) identify(item reflect.Value, keys []string) (HasIdentifier, bool) {
+	for item.Kind() == reflect.Interface && !item.IsNil() {
+		item = item.Elem()
+	}
+	if item.Kind() == reflect.Interface || (item.Kind() == reflect.Ptr && item.IsNil()) {
+		return nil, false
+	}
+	v := reflect.Indirect(item)
+	if len(keys) > 0 {
+		if v.Kind() != reflect.Struct {
+			return nil, false
+		}
+		parts := make([]interface{}, len(keys))
+		for i, name := range keys {
+			f := v.FieldByName(name)
+			if !f.IsValid() {
+				return nil, false
+			}
+			part, ok := fieldValue(f)
+			if !ok {
+				return nil, false
+			}
+			parts[i] = part
+		}
+		return newKeyed(item.Interface(), parts), true
+	}
+	if fn, ok := df.opts.keyFuncs[v.Type()]; ok {
+		return newKeyed(item.Interface(), fn(v.Interface())), true
+	}
+	if p, ok := item.Interface().(HasIdentifier); ok {
+		return p, true
+	}
+	if fields := idFields(v.Type()); len(fields) > 0 {
+		parts := make([]interface{}, len(fields))
+		for i, f := range fields {
+			//parseField rejects the id tag on the fields fieldValue cannot read
+			parts[i], _ = fieldValue(v.Field(f))
+		}
+		return newKeyed(item.Interface(), parts), true
+	}
+	return nil, false
+}
+
+//fieldValue returns the value of the struct field f. The values of unexported fields are copied,
+//which is possible for the basic kinds only.
+func fieldValue(f reflect.Value) (interface{}, bool) {
+	if f.CanInterface() {
+		return f.Interface(), true
+	}
+	c := reflect.New(f.Type()).Elem()
+	switch k := f.Kind(); {
+	case k == reflect.String:
+		c.SetString(f.String())
+	case k == reflect.Bool:
+		c.SetBool(f.Bool())
+	case k >= reflect.Int && k <= reflect.Int64:
+		c.SetInt(f.Int())
+	case k >= reflect.Uint && k <= reflect.Uintptr:
+		c.SetUint(f.Uint())
+	case k == reflect.Float32 || k == reflect.Float64:
+		c.SetFloat(f.Float())
+	default:
+		return nil, false
+	}
+	return c.Interface(), true
+}
+
+//readableField reports whether fieldValue can read the field f
+func readableField(f reflect.StructField) bool {
+	if f.PkgPath == "" {
+		return true
+	}
+	switch k := f.Type.Kind(); {
+	case k == reflect.String, k == reflect.Bool, k >= reflect.Int && k <= reflect.Uintptr, k == reflect.Float32, k == reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+//typedKey returns a comparable value identifying p, in which the key parts keep their type.
+//It fails if a part of the key is not comparable.
+func typedKey(p HasIdentifier) (interface{}, bool) {
+	k, ok := p.(keyed)
+	if !ok {
+		return p.ID(), true
+	}
+	for _, part := range k.Parts {
+		if part == nil || !reflect.TypeOf(part).Comparable() {
+			return nil, false
+		}
+	}
+	if len(k.Parts) == 1 {
+		return k.Parts[0], true
+	}
+	//an array of interface{} compares its elements with their dynamic type
+	a := reflect.New(reflect.ArrayOf(len(k.Parts), interfaceType)).Elem()
+	for i, part := range k.Parts {
+		a.Index(i).Set(reflect.ValueOf(part))
+	}
+	return a.Interface(), true
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type taggedRecord struct {
+	Name  string `diff:"id"`
+	Value int
+}
+
+type plainRecord struct {
+	Name  string
+	Value int
+}
+
+type zonedRecord struct {
+	Region string `diff:"id"`
+	Zone   string `diff:"id"`
+	Num    int    `diff:"id"`
+	Size   int
+}
+
+type anyKeyRecord struct {
+	K interface{}
+	V int
+}
+
+type keyHolder struct {
+	N       string
+	Tagged  []taggedRecord
+	ByField []plainRecord `diff:"key=Name"`
+	ByFunc  []*plainRecord
+	ByValue []plainRecord
+	Zoned   []zonedRecord
+	ByPair  []plainRecord `diff:"key=Name+Value"`
+	AnyKey  []anyKeyRecord
+}
+
+func (h keyHolder) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2Keys(t *testing.T) {
+
+	keyFunc := WithKeyFunc(reflect.TypeOf(plainRecord{}), func(i interface{}) string {
+		return "k-" + i.(plainRecord).Name
+	})
+
+	anyKeyFunc := WithKeyPartsFunc(reflect.TypeOf(anyKeyRecord{}), func(i interface{}) []interface{} {
+		return []interface{}{i.(anyKeyRecord).K}
+	})
+
+	testcase := []struct {
+		name     string
+		current  keyHolder
+		proposed keyHolder
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "id tag",
+			current:  keyHolder{N: "H", Tagged: []taggedRecord{{Name: "a", Value: 1}, {Name: "b"}}},
+			proposed: keyHolder{N: "H", Tagged: []taggedRecord{{Name: "a", Value: 2}, {Name: "c"}}},
+			report:   []string{"H.Tagged:Deleted=b", "H.Tagged:Modified=a", "H.Tagged:New=c", "a.Value:1->2"},
+		},
+		{
+			name:     "key tag",
+			current:  keyHolder{N: "H", ByField: []plainRecord{{Name: "a", Value: 1}, {Name: "b"}}},
+			proposed: keyHolder{N: "H", ByField: []plainRecord{{Name: "b"}, {Name: "a", Value: 2}}},
+			report:   []string{"H.ByField:Modified=a", "a.Value:1->2"},
+		},
+		{
+			name:     "key func",
+			current:  keyHolder{N: "H", ByFunc: []*plainRecord{{Name: "a", Value: 1}}},
+			proposed: keyHolder{N: "H", ByFunc: []*plainRecord{{Name: "a", Value: 2}, {Name: "b"}}},
+			opts:     []Option{keyFunc},
+			report:   []string{"H.ByFunc:Modified=k-a", "H.ByFunc:New=k-b", "k-a.Value:1->2"},
+		},
+		{
+			name:     "key func on values and pointers",
+			current:  keyHolder{N: "H", ByFunc: []*plainRecord{{Name: "a"}}, ByValue: []plainRecord{{Name: "b", Value: 1}}},
+			proposed: keyHolder{N: "H", ByFunc: []*plainRecord{{Name: "a"}}, ByValue: []plainRecord{{Name: "b", Value: 2}}},
+			opts:     []Option{keyFunc},
+			report:   []string{"H.ByValue:Modified=k-b", "k-b.Value:1->2"},
+		},
+		{
+			name:     "composite id tags",
+			current:  keyHolder{N: "H", Zoned: []zonedRecord{{Region: "eu", Zone: "a", Num: 1, Size: 1}, {Region: "eu", Zone: "b", Num: 1}}},
+			proposed: keyHolder{N: "H", Zoned: []zonedRecord{{Region: "eu", Zone: "a", Num: 1, Size: 2}, {Region: "eu", Zone: "b", Num: 2}}},
+			report:   []string{"H.Zoned:Deleted=eu/b/1", "H.Zoned:Modified=eu/a/1", "H.Zoned:New=eu/b/2", "eu/a/1.Size:1->2"},
+		},
+		{
+			name:     "composite key tag",
+			current:  keyHolder{N: "H", ByPair: []plainRecord{{Name: "a", Value: 1}}},
+			proposed: keyHolder{N: "H", ByPair: []plainRecord{{Name: "a", Value: 2}}},
+			report:   []string{"H.ByPair:Deleted=a/1", "H.ByPair:New=a/2"},
+		},
+		{
+			name:     "typed key parts",
+			current:  keyHolder{N: "H", AnyKey: []anyKeyRecord{{K: 1}, {K: 2, V: 1}}},
+			proposed: keyHolder{N: "H", AnyKey: []anyKeyRecord{{K: "1"}, {K: 2, V: 2}}},
+			opts:     []Option{anyKeyFunc},
+			report:   []string{"2.V:1->2", "H.AnyKey:Deleted=1", "H.AnyKey:Modified=2", "H.AnyKey:New=1"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, test.opts...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+func TestCheckDiff2KeyParts(t *testing.T) {
+	current := keyHolder{N: "H", Zoned: []zonedRecord{{Region: "eu", Zone: "a", Num: 1, Size: 1}}}
+	proposed := keyHolder{N: "H", Zoned: []zonedRecord{{Region: "eu", Zone: "a", Num: 1, Size: 2}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	modified := d.Composition["Zoned"].Modified
+	if len(modified) != 1 {
+		t.Fatalf("expected one modified item, got %v", modified)
+	}
+	expected := []interface{}{"eu", "a", 1}
+	if !reflect.DeepEqual(modified[0].Key, expected) {
+		t.Errorf("bad key parts. Expected %v, got %v", expected, modified[0].Key)
+	}
+}
+
+type privateIDRecord struct {
+	name  string `diff:"id"`
+	Value int
+}
+
+type privateKeyRecord struct {
+	name  string
+	inner struct{ A int }
+	Value int
+}
+
+type privateHolder struct {
+	N      string
+	ByTag  []privateIDRecord
+	ByKey  []privateKeyRecord `diff:"key=name"`
+	BadKey []privateKeyRecord `diff:"key=inner"`
+}
+
+type badPrivateIDRecord struct {
+	inner struct{ A int } `diff:"id"`
+}
+
+func (h privateHolder) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2UnexportedKeys(t *testing.T) {
+	current := privateHolder{N: "H", ByTag: []privateIDRecord{{name: "a", Value: 1}}, ByKey: []privateKeyRecord{{name: "a", Value: 1}}}
+	proposed := privateHolder{N: "H", ByTag: []privateIDRecord{{name: "a", Value: 2}}, ByKey: []privateKeyRecord{{name: "b", Value: 1}}}
+	d, err := checkDiff2(current, proposed, WithCollectErrors())
+	if !errors.Is(err, ErrInvalidTag) || !strings.Contains(err.Error(), "BadKey:") {
+		t.Errorf("expected ErrInvalidTag at BadKey, got %v", err)
+	}
+	if fi := structInfoOf(reflect.TypeOf(badPrivateIDRecord{})).fields[0]; !errors.Is(fi.err, ErrInvalidTag) || fi.id {
+		t.Errorf("expected ErrInvalidTag for an id tag on an unexported struct, got %v", fi.err)
+	}
+	report := diffReport(d, []string{})
+	sort.Strings(report)
+	expected := []string{"H.ByKey:Deleted=a", "H.ByKey:New=b", "H.ByTag:Modified=a", "a.Value:1->2"}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("did not give expected report:\nExpected:\n%v\nGot:\n%v\n", expected, report)
+	}
+}
+
+type privateIDHolder struct {
+	N     string
+	ByTag []privateIDRecord
+}
+
+func (h privateIDHolder) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2UnexportedIDRenamed(t *testing.T) {
+	current := privateIDHolder{N: "H", ByTag: []privateIDRecord{{name: "a", Value: 1}}}
+	proposed := privateIDHolder{N: "H", ByTag: []privateIDRecord{{name: "b", Value: 1}}}
+	d, err := checkDiff2(current, proposed, WithRenameDetection(0.5))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	report := diffReport(d, []string{})
+	sort.Strings(report)
+	expected := []string{"H.ByTag:Renamed=a->b"}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("did not give expected report:\nExpected:\n%v\nGot:\n%v\n", expected, report)
+	}
+}
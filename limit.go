@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+//ErrLimitExceeded is matched by every LimitError
+var ErrLimitExceeded = errors.New("diff limit exceeded")
+
+//LimitError is returned when a diff run exceeds one of its configured limits.
+//The diff returned along with it holds the changes found before the limit was hit.
+type LimitError struct {
+	Path  string
+	Limit string // "depth", "changes" or "composition size"
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	msg := fmt.Sprintf("diff limit exceeded: max %s %d", e.Limit, e.Max)
+	if e.Path == "" {
+		return msg
+	}
+	return e.Path + ": " + msg
+}
+
+//Is makes errors.Is(err, ErrLimitExceeded) true for any LimitError
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+//isInterruption reports whether err stopped the run (limit or cancellation) rather than invalidated it.
+//In that case the partial diff is still meaningful and is returned to the caller.
+func isInterruption(err error) bool {
+	return errors.Is(err, ErrLimitExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (df *differ) checkDepth(path string, depth int) error {
+	if df.opts.maxDepth > 0 && depth > df.opts.maxDepth {
+		return &LimitError{Path: path, Limit: "depth", Max: df.opts.maxDepth}
+	}
+	return nil
+}
+
+//countChange must be called before recording a change at path
+func (df *differ) countChange(path string) error {
+	if df.opts.maxChanges > 0 && df.changes >= df.opts.maxChanges {
+		return &LimitError{Path: path, Limit: "changes", Max: df.opts.maxChanges}
+	}
+	df.changes++
+	return nil
+}
+
+func (df *differ) checkCompositionSize(path string, n int) error {
+	if df.opts.maxCompositionSize > 0 && n > df.opts.maxCompositionSize {
+		return &LimitError{Path: path, Limit: "composition size", Max: df.opts.maxCompositionSize}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCheckDiff2Limits(t *testing.T) {
+
+	current := myStruct{P: "A", F1: 1, F3: []myStruct{
+		{P: "B1", F1: 1, F3: []myStruct{{P: "C1", F1: 1}}},
+		{P: "B2"},
+	}}
+	proposed := myStruct{P: "A", F1: 2, F3: []myStruct{
+		{P: "B1", F1: 1, F3: []myStruct{{P: "C1", F1: 2}}},
+		{P: "B3"},
+	}}
+
+	testcase := []struct {
+		name          string
+		opts          []Option
+		expectedLimit string
+		report        []string
+	}{
+		{
+			name:   "no limit",
+			report: []string{"A.F1:1->2", "A.F3:Deleted=B2", "A.F3:Modified=B1", "A.F3:New=B3", "B1.F3:Modified=C1", "C1.F1:1->2"},
+		},
+		{
+			name:          "depth",
+			opts:          []Option{WithMaxDepth(1)},
+			expectedLimit: "depth",
+			report:        []string{"A.F1:1->2", "A.F3:Deleted=B2", "A.F3:New=B3"},
+		},
+		{
+			name:          "changes",
+			opts:          []Option{WithMaxChanges(2)},
+			expectedLimit: "changes",
+			report:        []string{"A.F1:1->2", "A.F3:New=B3"},
+		},
+		{
+			name:          "composition size",
+			opts:          []Option{WithMaxCompositionSize(1)},
+			expectedLimit: "composition size",
+			report:        []string{"A.F1:1->2"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(current, proposed, test.opts...)
+		if test.expectedLimit == "" && err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		if test.expectedLimit != "" {
+			var le *LimitError
+			if !errors.As(err, &le) || !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Test %s, expected a LimitError, got %v", test.name, err)
+				continue
+			}
+			if le.Limit != test.expectedLimit {
+				t.Errorf("Test %s, bad limit. Expected %s, got %s", test.name, test.expectedLimit, le.Limit)
+			}
+		}
+		if d == nil {
+			t.Errorf("Test %s, no partial diff returned", test.name)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+func TestCheckDiff2ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := checkDiff2Context(ctx, myStruct{P: "A"}, myStruct{P: "A", F1: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	_, _, _, err = checkDiffInCompositionContext(ctx, []PathI{"A"}, []PathI{"B"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from composition, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+//WithBestMatch compares the slices of structs that cannot be identified element by element.
+//The elements of both sides are paired so that the total diff is minimal, unpaired elements are reported new or deleted.
+//Such elements are identified by their index in their slice.
+func WithBestMatch() Option {
+	return func(o *options) {
+		o.bestMatch = true
+	}
+}
+
+//matchable reports whether the elements of type t can be paired by best match
+func (df *differ) matchable(t reflect.Type) bool {
+	return df.opts.bestMatch && derefType(t).Kind() == reflect.Struct
+}
+
+//matchComposition pairs the elements of two slices by minimal diff cost and records the result in d
+func (df *differ) matchComposition(d *diff, fieldName, fPath string, current, proposed reflect.Value, depth int) error {
+	if df.equal(current, proposed) {
+		return nil
+	}
+	nc, np := current.Len(), proposed.Len()
+	if err := df.checkCompositionSize(fPath, nc); err != nil {
+		return err
+	}
+	if err := df.checkCompositionSize(fPath, np); err != nil {
+		return err
+	}
+
+	fields := structInfoOf(derefType(current.Type().Elem())).compared
+	//Elements are paired only if they have at least one field in common.
+	//Leaving an element unpaired costs half its fields, pairing two elements costs their changed fields.
+	//Elements without compared fields still cost something to leave unpaired, so that equal ones are paired.
+	unpaired := math.Max(float64(fields)/2, 0.5)
+	never := unpaired * 8
+
+	n := nc + np
+	cost := make([][]float64, n)
+	diffs := make([][]*diff, nc)
+	for i := range cost {
+		cost[i] = make([]float64, n)
+	}
+	trial := df.trial()
+	for i := 0; i < nc; i++ {
+		diffs[i] = make([]*diff, np)
+		for j := 0; j < np; j++ {
+			if err := df.ctx.Err(); err != nil {
+				return &DiffError{Path: fPath, Err: err}
+			}
+			cost[i][j] = never
+			c, p := current.Index(i), proposed.Index(j)
+			if reflect.Indirect(c).Kind() != reflect.Struct || reflect.Indirect(p).Kind() != reflect.Struct {
+				//nil pointers
+				continue
+			}
+			md, err := trial.content(itemPath(fPath, strconv.Itoa(i)), indexed(c, i), indexed(p, i), depth+1)
+			if err != nil {
+				continue
+			}
+			//equal elements are paired even when they have no field to compare
+			if changed := len(md.Param) + len(md.Composition); changed == 0 || changed < fields {
+				diffs[i][j] = md
+				cost[i][j] = float64(changed)
+			}
+		}
+		for j := np; j < n; j++ {
+			cost[i][j] = unpaired
+		}
+	}
+	for i := nc; i < n; i++ {
+		for j := 0; j < np; j++ {
+			cost[i][j] = unpaired
+		}
+	}
+
+	pairedProposed := make([]bool, np)
+	for i, j := range assign(cost) {
+		if i >= nc {
+			continue
+		}
+		if j >= np || diffs[i][j] == nil {
+			if err := df.addDeleted(d, fieldName, itemPath(fPath, strconv.Itoa(i)), indexed(current.Index(i), i)); err != nil {
+				return err
+			}
+			continue
+		}
+		pairedProposed[j] = true
+		if !diffs[i][j].Empty() {
+			//the pair is diffed again to account for its changes in the run
+			if err := df.addModified(d, fieldName, itemPath(fPath, strconv.Itoa(i)), indexed(current.Index(i), i), indexed(proposed.Index(j), i), depth); err != nil {
+				return err
+			}
+		}
+	}
+	for j := 0; j < np; j++ {
+		if !pairedProposed[j] {
+			if err := df.addNew(d, fieldName, itemPath(fPath, strconv.Itoa(j)), indexed(proposed.Index(j), j)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//indexed identifies an element of a slice by its index
+func indexed(v reflect.Value, i int) keyed {
+	k := newKeyed(v.Interface(), []interface{}{i})
+	k.Positional = true
+	return k
+}
+
+//assign solves the assignment problem for a square cost matrix with the Hungarian algorithm.
+//It returns for each row the column assigned to it.
+func assign(cost [][]float64) []int {
+	n := len(cost)
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := cost[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	rows := make([]int, n)
+	for j := 1; j <= n; j++ {
+		if p[j] != 0 {
+			rows[p[j]-1] = j - 1
+		}
+	}
+	return rows
+}
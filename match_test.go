@@ -0,0 +1,116 @@
+package api
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type matchHolder struct {
+	N       string
+	Records []plainRecord
+}
+
+func (h matchHolder) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2BestMatch(t *testing.T) {
+
+	testcase := []struct {
+		name     string
+		current  []plainRecord
+		proposed []plainRecord
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "whole slice without best match",
+			current:  []plainRecord{{Name: "a", Value: 1}},
+			proposed: []plainRecord{{Name: "a", Value: 2}},
+			report:   []string{"H.Records:[{a 1}]->[{a 2}]"},
+		},
+		{
+			name:     "reordered",
+			current:  []plainRecord{{Name: "a", Value: 1}, {Name: "b", Value: 2}},
+			proposed: []plainRecord{{Name: "b", Value: 2}, {Name: "a", Value: 1}},
+			opts:     []Option{WithBestMatch()},
+			report:   []string{},
+		},
+		{
+			name:     "modified new and deleted",
+			current:  []plainRecord{{Name: "a", Value: 1}, {Name: "b", Value: 2}, {Name: "c", Value: 3}},
+			proposed: []plainRecord{{Name: "x", Value: 9}, {Name: "c", Value: 4}, {Name: "a", Value: 1}},
+			opts:     []Option{WithBestMatch()},
+			report:   []string{"2.Value:3->4", "H.Records:Deleted=1", "H.Records:Modified=2", "H.Records:New=0"},
+		},
+		{
+			name:     "minimal total cost",
+			current:  []plainRecord{{Name: "a", Value: 1}, {Name: "b", Value: 1}},
+			proposed: []plainRecord{{Name: "b", Value: 2}, {Name: "a", Value: 2}},
+			opts:     []Option{WithBestMatch()},
+			report:   []string{"0.Value:1->2", "1.Value:1->2", "H.Records:Modified=0", "H.Records:Modified=1"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(matchHolder{N: "H", Records: test.current}, matchHolder{N: "H", Records: test.proposed}, test.opts...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	cost := [][]float64{
+		{4, 1, 3},
+		{2, 0, 5},
+		{3, 2, 2},
+	}
+	rows := assign(cost)
+	expected := []int{1, 0, 2}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("bad assignment. Expected %v, got %v", expected, rows)
+	}
+}
+
+type emptyS struct{}
+
+type emptyHolderList struct {
+	N     string
+	Items []emptyS
+}
+
+func (h emptyHolderList) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2BestMatchNoField(t *testing.T) {
+	testcase := []struct {
+		name     string
+		current  []emptyS
+		proposed []emptyS
+		report   []string
+	}{
+		{name: "equal", current: []emptyS{{}}, proposed: []emptyS{{}}, report: []string{}},
+		{name: "one removed", current: []emptyS{{}, {}}, proposed: []emptyS{{}}, report: []string{"H.Items:Deleted=1"}},
+	}
+	for _, test := range testcase {
+		d, err := checkDiff2(emptyHolderList{N: "H", Items: test.current}, emptyHolderList{N: "H", Items: test.proposed}, WithBestMatch())
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+//WithMoveDetection links the items deleted from a composition to the items with the same type and identifier
+//added to another composition, anywhere in the diff. They are reported once in the Moved changes of the root diff.
+func WithMoveDetection() Option {
+	return func(o *options) {
+		o.detectMoves = true
+	}
+}
+
+//moved records an identified object that left a composition for another one
+type moved struct {
+	ID       string
+	From     string // path of the object in current, composition items being addressed as F3[B1]
+	To       string // path of the object in proposed
+	Current  interface{}
+	Proposed interface{}
+	Diff     *diff // changes of content, nil if none
+	//change rules of the compositions the object left and joined, checked by validateDiff
+	FromImmutable  bool
+	FromAppendOnly bool
+	FromCreateOnly bool
+	ToImmutable    bool
+	ToCreateOnly   bool
+}
+
+//compositionPath returns the path of the composition holding the item at path
+func (m moved) compositionPath(path string) string {
+	return path[:len(path)-len(m.ID)-2]
+}
+
+//compositionItem locates a New or Deleted item in a diff tree
+type compositionItem struct {
+	d     *diff
+	field string
+	index int
+	item  HasIdentifier
+}
+
+func (c compositionItem) path() string {
+	return itemPath(fieldPath(c.d.Path, c.field), c.item.ID())
+}
+
+//positional reports whether item is identified by its index only, it then cannot be found elsewhere
+func positional(item interface{}) bool {
+	k, ok := item.(keyed)
+	return ok && k.Positional
+}
+
+//collectItems gathers the identified New and Deleted items of the tree d
+func collectItems(d *diff, deleted, added *[]compositionItem) {
+	//compositions are walked by name so that the first of the candidates of a move is always the same
+	for _, field := range sortedCompositions(d) {
+		dc := d.Composition[field]
+		for i, n := range dc.Deleted {
+			if p, ok := n.(HasIdentifier); ok && !positional(n) {
+				*deleted = append(*deleted, compositionItem{d: d, field: field, index: i, item: p})
+			}
+		}
+		for i, n := range dc.New {
+			if p, ok := n.(HasIdentifier); ok && !positional(n) {
+				*added = append(*added, compositionItem{d: d, field: field, index: i, item: p})
+			}
+		}
+		for i := range dc.Modified {
+			collectItems(&dc.Modified[i], deleted, added)
+		}
+	}
+}
+
+//detectMoves replaces the Deleted and New items matching by type and identifier with Moved changes on the root d
+func (df *differ) detectMoves(d *diff) {
+	var deleted, added []compositionItem
+	collectItems(d, &deleted, &added)
+
+	addedByKey := map[compositionKey][]int{}
+	for i, a := range added {
+		if key, ok := typedKey(a.item); ok {
+			k := compositionKey{t: concreteType(a.item), key: key}
+			addedByKey[k] = append(addedByKey[k], i)
+		}
+	}
+
+	removed := map[*diff]map[string]map[int]bool{}
+	remove := func(c compositionItem, kind string) {
+		if removed[c.d] == nil {
+			removed[c.d] = map[string]map[int]bool{}
+		}
+		if removed[c.d][kind+c.field] == nil {
+			removed[c.d][kind+c.field] = map[int]bool{}
+		}
+		removed[c.d][kind+c.field][c.index] = true
+	}
+
+	for _, del := range deleted {
+		key, ok := typedKey(del.item)
+		if !ok {
+			continue
+		}
+		k := compositionKey{t: concreteType(del.item), key: key}
+		candidates := addedByKey[k]
+		if len(candidates) == 0 {
+			continue
+		}
+		add := added[candidates[0]]
+		addedByKey[k] = candidates[1:]
+
+		m := moved{ID: del.item.ID(), From: del.path(), To: add.path()}
+		m.Current, m.Proposed = df.redactObject(m.From, del.item), df.redactObject(m.To, add.item)
+		from, to := del.d.Composition[del.field], add.d.Composition[add.field]
+		m.FromImmutable, m.FromAppendOnly, m.FromCreateOnly = from.Immutable, from.AppendOnly, from.CreateOnly
+		m.ToImmutable, m.ToCreateOnly = to.Immutable, to.CreateOnly
+		//the content is compared by a run of its own, on the original items, the changes it holds are not accounted in the limits
+		md, err := df.trial().diff(m.To, del.item, add.item, 0)
+		if err == nil && !md.Empty() {
+			m.Diff = md
+		}
+		d.Moved = append(d.Moved, m)
+		remove(del, "deleted.")
+		remove(add, "new.")
+	}
+	pruneItems(d, removed)
+	df.redactItems(d)
+}
+
+//redactItems redacts the New and Deleted items left in the tree d by detectMoves
+func (df *differ) redactItems(d *diff) {
+	for field, dc := range d.Composition {
+		fPath := fieldPath(d.Path, field)
+		for i, item := range dc.Deleted {
+			dc.Deleted[i] = df.redactObject(dc.itemPath(fPath, item), item)
+		}
+		for i, item := range dc.New {
+			dc.New[i] = df.redactObject(dc.itemPath(fPath, item), item)
+		}
+		for i := range dc.Modified {
+			df.redactItems(&dc.Modified[i])
+		}
+	}
+}
+
+//pruneItems removes from the tree d the New and Deleted items marked in removed, then the compositions left empty
+func pruneItems(d *diff, removed map[*diff]map[string]map[int]bool) {
+	for field, dc := range d.Composition {
+		dc.Deleted = filterItems(dc.Deleted, removed[d]["deleted."+field])
+		dc.New = filterItems(dc.New, removed[d]["new."+field])
+		modified := dc.Modified[:0]
+		for i := range dc.Modified {
+			pruneItems(&dc.Modified[i], removed)
+			if !dc.Modified[i].Empty() {
+				modified = append(modified, dc.Modified[i])
+			}
+		}
+		dc.Modified = modified
+		if len(dc.Deleted) == 0 && len(dc.New) == 0 && len(dc.Modified) == 0 && len(dc.TypeChanged) == 0 && len(dc.Renamed) == 0 {
+			delete(d.Composition, field)
+			continue
+		}
+		d.Composition[field] = dc
+	}
+}
+
+func filterItems(items []interface{}, removed map[int]bool) []interface{} {
+	if len(removed) == 0 {
+		return items
+	}
+	var kept []interface{}
+	for i, item := range items {
+		if !removed[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCheckDiff2Moves(t *testing.T) {
+
+	testcase := []struct {
+		name     string
+		current  myStruct
+		proposed myStruct
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "detection off",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F3: []myStruct{{P: "C1"}}}, {P: "B2"}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B1"}, {P: "B2", F3: []myStruct{{P: "C1"}}}}},
+			report:   []string{"A.F3:Modified=B1", "A.F3:Modified=B2", "B1.F3:Deleted=C1", "B2.F3:New=C1"},
+		},
+		{
+			name:     "moved across parents",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F3: []myStruct{{P: "C1"}}}, {P: "B2"}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B1"}, {P: "B2", F3: []myStruct{{P: "C1"}}}}},
+			opts:     []Option{WithMoveDetection()},
+			report:   []string{"C1:Moved=F3[B1].F3[C1]->F3[B2].F3[C1]"},
+		},
+		{
+			name:     "moved across fields with content change",
+			current:  myStruct{P: "A", F1: 1, F3: []myStruct{{P: "B1", F1: 1}, {P: "B2"}}},
+			proposed: myStruct{P: "A", F1: 2, F3: []myStruct{{P: "B2"}}, F4: []myStruct{{P: "B1", F1: 2}}},
+			opts:     []Option{WithMoveDetection()},
+			report:   []string{"A.F1:1->2", "B1.F1:1->2", "B1:Moved=F3[B1]->F4[B1]"},
+		},
+		{
+			name:     "not moved",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1"}}},
+			proposed: myStruct{P: "A", F4: []myStruct{{P: "B2"}}},
+			opts:     []Option{WithMoveDetection()},
+			report:   []string{"A.F3:Deleted=B1", "A.F4:New=B2"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, test.opts...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+type positionHolder struct {
+	N  string
+	L1 []plainRecord
+	L2 []plainRecord
+}
+
+func (h positionHolder) ID() string {
+	return h.N
+}
+
+func TestCheckDiff2MovesSkipPositions(t *testing.T) {
+	//elements identified by their index in a best match are not the same objects in another slice
+	current := positionHolder{N: "H", L1: []plainRecord{{Name: "a", Value: 1}}}
+	proposed := positionHolder{N: "H", L2: []plainRecord{{Name: "b", Value: 9}}}
+	d, err := checkDiff2(current, proposed, WithBestMatch(), WithMoveDetection())
+	if err != nil {
+		t.Fatalf("Test failed with error %v", err)
+	}
+	report := diffReport(d, []string{})
+	sort.Strings(report)
+	expected := []string{"H.L1:Deleted=0", "H.L2:New=0"}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Test did not give expected report:\nExpected:\n%v\nGot:\n%v\n", expected, report)
+	}
+}
+
+func TestCheckDiff2MovesDeterministic(t *testing.T) {
+	//the item B1 could move to F4 or F5, the first composition by name is chosen
+	current := myStruct{P: "A", F3: []myStruct{{P: "B1"}}}
+	proposed := myStruct{P: "A", F4: []myStruct{{P: "B1"}}, F5: []HasIdentifier{myStruct{P: "B1"}}}
+	for i := 0; i < 20; i++ {
+		d, err := checkDiff2(current, proposed, WithMoveDetection())
+		if err != nil {
+			t.Fatalf("failed with error %v", err)
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		expected := []string{"A.F5:New=B1", "B1:Moved=F3[B1]->F4[B1]"}
+		if !reflect.DeepEqual(report, expected) {
+			t.Fatalf("did not give expected report:\nExpected:\n%v\nGot:\n%v\n", expected, report)
+		}
+	}
+}
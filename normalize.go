@@ -0,0 +1,96 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+)
+
+//Normalizer returns the canonical form of a value, the form in which it is compared.
+//The diff keeps reporting the original values.
+type Normalizer func(interface{}) interface{}
+
+//pathNormalizer applies a Normalizer to the fields whose path matches a pattern
+type pathNormalizer struct {
+	pattern string
+	fn      Normalizer
+}
+
+//normalizers are the normalizers named in diff:"normalize=..." tags, extended with WithNamedNormalizer
+var normalizers = map[string]Normalizer{
+	"lower": stringNormalizer(strings.ToLower),
+	"upper": stringNormalizer(strings.ToUpper),
+	"trim":  stringNormalizer(strings.TrimSpace),
+	"space": stringNormalizer(func(s string) string { return strings.Join(strings.Fields(s), " ") }),
+}
+
+//stringNormalizer makes a Normalizer of fn for the values of string kind, keeping their type. Other values are not changed.
+func stringNormalizer(fn func(string) string) Normalizer {
+	return func(i interface{}) interface{} {
+		v := reflect.ValueOf(i)
+		if v.Kind() != reflect.String {
+			return i
+		}
+		return reflect.ValueOf(fn(v.String())).Convert(v.Type()).Interface()
+	}
+}
+
+//WithNormalizer compares the values of type t, at any depth, in the form returned by fn
+func WithNormalizer(t reflect.Type, fn Normalizer) Option {
+	return func(o *options) {
+		if o.typeNormalizers == nil {
+			o.typeNormalizers = map[reflect.Type]Normalizer{}
+		}
+		o.typeNormalizers[t] = fn
+	}
+}
+
+//WithPathNormalizer compares the fields whose path matches pattern in the form returned by fn.
+//Patterns are those of WithRedactedPaths.
+func WithPathNormalizer(pattern string, fn Normalizer) Option {
+	return func(o *options) {
+		o.pathNormalizers = append(o.pathNormalizers, pathNormalizer{pattern: pattern, fn: fn})
+	}
+}
+
+//WithNamedNormalizer makes fn available to diff:"normalize=name" tags, besides the built-in
+//lower, upper, trim and space (trim and collapse the runs of spaces) normalizers
+func WithNamedNormalizer(name string, fn Normalizer) Option {
+	return func(o *options) {
+		if o.namedNormalizers == nil {
+			o.namedNormalizers = map[string]Normalizer{}
+		}
+		o.namedNormalizers[name] = fn
+	}
+}
+
+//normalize applies the path and tag normalizers of the field at fPath to its two values.
+//The type normalizers are applied by equal.
+func (df *differ) normalize(fi *fieldInfo, fPath string, c, p reflect.Value) (reflect.Value, reflect.Value, error) {
+	var fns []Normalizer
+	for _, pn := range df.opts.pathNormalizers {
+		if matchPath(pn.pattern, fPath) {
+			fns = append(fns, pn.fn)
+		}
+	}
+	for _, name := range fi.normalize {
+		fn, ok := df.opts.namedNormalizers[name]
+		if !ok {
+			fn, ok = normalizers[name]
+		}
+		if !ok {
+			return c, p, newDiffError(fPath, ErrInvalidTag, "unknown normalizer %q", name)
+		}
+		fns = append(fns, fn)
+	}
+	for _, fn := range fns {
+		c, p = applyNormalizer(fn, c), applyNormalizer(fn, p)
+	}
+	return c, p, nil
+}
+
+func applyNormalizer(fn Normalizer, v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	return reflect.ValueOf(fn(v.Interface()))
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type hostname string
+
+type endpoint struct {
+	Host hostname
+	Port int
+}
+
+type service struct {
+	N        string
+	Owner    string `diff:"normalize=lower+trim"`
+	Command  string `diff:"normalize=space"`
+	Image    string `diff:"normalize=registry"`
+	Main     endpoint
+	Backends []endpoint `diff:"value"`
+	Labels   map[string]string
+}
+
+func (s service) ID() string {
+	return s.N
+}
+
+type badNormalizeStruct struct {
+	P    string
+	Name string `diff:"normalize=unknown"`
+}
+
+func (b badNormalizeStruct) ID() string {
+	return b.P
+}
+
+func TestCheckDiff2Normalize(t *testing.T) {
+	lowerHost := WithNormalizer(reflect.TypeOf(hostname("")), func(i interface{}) interface{} {
+		return hostname(strings.ToLower(string(i.(hostname))))
+	})
+	registry := WithNamedNormalizer("registry", func(i interface{}) interface{} {
+		return strings.TrimPrefix(i.(string), "docker.io/")
+	})
+	labels := WithPathNormalizer("Labels", func(i interface{}) interface{} {
+		m := map[string]string{}
+		for k, v := range i.(map[string]string) {
+			if !strings.HasPrefix(k, "generated/") {
+				m[k] = v
+			}
+		}
+		return m
+	})
+
+	testcase := []struct {
+		name     string
+		current  service
+		proposed service
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "tag normalizers",
+			current:  service{N: "S", Owner: "Team-A", Command: "run  --fast"},
+			proposed: service{N: "S", Owner: " team-a ", Command: " run --fast"},
+			report:   []string{},
+		},
+		{
+			name:     "original values are reported",
+			current:  service{N: "S", Owner: "Team-A"},
+			proposed: service{N: "S", Owner: "Team-B "},
+			report:   []string{"S.Owner:Team-A->Team-B "},
+		},
+		{
+			name:     "named normalizer",
+			current:  service{N: "S", Image: "docker.io/nginx"},
+			proposed: service{N: "S", Image: "nginx"},
+			report:   []string{},
+		},
+		{
+			name:     "type normalizer at any depth",
+			current:  service{N: "S", Main: endpoint{Host: "A", Port: 1}, Backends: []endpoint{{Host: "B"}}},
+			proposed: service{N: "S", Main: endpoint{Host: "a", Port: 1}, Backends: []endpoint{{Host: "b"}}},
+			opts:     []Option{lowerHost},
+			report:   []string{},
+		},
+		{
+			name:     "without type normalizer",
+			current:  service{N: "S", Backends: []endpoint{{Host: "B"}}},
+			proposed: service{N: "S", Backends: []endpoint{{Host: "b"}}},
+			report:   []string{"S.Backends:[{B 0}]->[{b 0}]"},
+		},
+		{
+			name:     "path normalizer",
+			current:  service{N: "S", Labels: map[string]string{"app": "x", "generated/hash": "1"}},
+			proposed: service{N: "S", Labels: map[string]string{"app": "x", "generated/hash": "2"}},
+			opts:     []Option{labels},
+			report:   []string{},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, append(test.opts, registry)...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+func TestCheckDiff2UnknownNormalizer(t *testing.T) {
+	_, err := checkDiff2(badNormalizeStruct{P: "A"}, badNormalizeStruct{P: "A"})
+	var de *DiffError
+	if !errors.Is(err, ErrInvalidTag) || !errors.As(err, &de) || de.Path != "Name" {
+		t.Errorf("expected ErrInvalidTag at Name, got %v", err)
+	}
+}
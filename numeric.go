@@ -0,0 +1,108 @@
+package api
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//delta is the numeric change of a Param holding an integer, a float or a duration.
+//It can be rendered as "+5" instead of "10->15", and applied as an increment on a value changed concurrently.
+type delta struct {
+	Type    reflect.Type
+	Int     int64   // proposed - current, for integers and durations
+	Float   float64 // proposed - current, for floats
+	Percent float64 // change relative to the current value, infinite if the current value is zero
+}
+
+//newDelta returns the delta from current to proposed, or nil if the values are not numbers of the same type
+func newDelta(current, proposed reflect.Value) *delta {
+	if !current.IsValid() || !proposed.IsValid() || current.Type() != proposed.Type() {
+		return nil
+	}
+	d := &delta{Type: current.Type()}
+	var c, p float64
+	switch current.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		d.Int = proposed.Int() - current.Int()
+		c, p = float64(current.Int()), float64(proposed.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		d.Int = int64(proposed.Uint() - current.Uint())
+		c, p = float64(current.Uint()), float64(proposed.Uint())
+	case reflect.Float32, reflect.Float64:
+		d.Float = proposed.Float() - current.Float()
+		c, p = current.Float(), proposed.Float()
+	default:
+		return nil
+	}
+	switch {
+	case c != 0:
+		d.Percent = (p - c) / math.Abs(c) * 100
+	case p > 0:
+		d.Percent = math.Inf(1)
+	case p < 0:
+		d.Percent = math.Inf(-1)
+	}
+	return d
+}
+
+func (d delta) isFloat() bool {
+	k := d.Type.Kind()
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+//String returns the signed delta, "+5", "-0.5" or "+1m30s"
+func (d delta) String() string {
+	switch {
+	case d.Type == durationType:
+		return sign(float64(d.Int)) + time.Duration(d.Int).String()
+	case d.isFloat():
+		return sign(d.Float) + strconv.FormatFloat(d.Float, 'g', -1, d.Type.Bits())
+	}
+	return sign(float64(d.Int)) + strconv.FormatInt(d.Int, 10)
+}
+
+//PercentString returns the signed percentage of change rounded to one decimal, "+50%", or "" if the current value was zero
+func (d delta) PercentString() string {
+	if math.IsInf(d.Percent, 0) || math.IsNaN(d.Percent) {
+		return ""
+	}
+	p := math.Round(d.Percent*10) / 10
+	return sign(p) + strconv.FormatFloat(p, 'f', -1, 64) + "%"
+}
+
+//Format returns the delta with its percentage, "+5, +50%", or the delta alone if it has none.
+//All the renderers show a delta in this form.
+func (d delta) Format() string {
+	if p := d.PercentString(); p != "" {
+		return d.String() + ", " + p
+	}
+	return d.String()
+}
+
+func sign(f float64) string {
+	if f > 0 {
+		return "+"
+	}
+	return ""
+}
+
+//Apply increments value by the delta, value being the current value possibly changed since the diff.
+//It fails if value is not of the type of the delta.
+func (d delta) Apply(value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.Type() != d.Type {
+		return nil, newDiffError("", ErrTypeMismatch, "cannot increment %T by a delta of %s", value, d.Type)
+	}
+	r := reflect.New(d.Type).Elem()
+	switch k := d.Type.Kind(); {
+	case d.isFloat():
+		r.SetFloat(v.Float() + d.Float)
+	case k >= reflect.Int && k <= reflect.Int64:
+		r.SetInt(v.Int() + d.Int)
+	default:
+		r.SetUint(v.Uint() + uint64(d.Int))
+	}
+	return r.Interface(), nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type quota struct {
+	N       string
+	Count   int
+	Free    uint
+	Ratio   float64
+	Timeout time.Duration
+	Name    string
+	Secret  int `diff:"sensitive"`
+}
+
+func (q quota) ID() string {
+	return q.N
+}
+
+func TestCheckDiff2Delta(t *testing.T) {
+	current := quota{N: "Q", Count: 10, Free: 5, Ratio: 0.5, Timeout: time.Minute, Name: "a", Secret: 1}
+	proposed := quota{N: "Q", Count: 15, Free: 3, Ratio: 0.25, Timeout: 90 * time.Second, Name: "b", Secret: 2}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+
+	testcase := []struct {
+		field   string
+		delta   string
+		percent string
+	}{
+		{field: "Count", delta: "+5", percent: "+50%"},
+		{field: "Free", delta: "-2", percent: "-40%"},
+		{field: "Ratio", delta: "-0.25", percent: "-50%"},
+		{field: "Timeout", delta: "+30s", percent: "+50%"},
+	}
+	for _, test := range testcase {
+		dl := d.Param[test.field].Delta
+		if dl == nil {
+			t.Errorf("no delta on %s", test.field)
+			continue
+		}
+		if dl.String() != test.delta || dl.PercentString() != test.percent {
+			t.Errorf("bad delta on %s. Expected %s %s, got %s %s", test.field, test.delta, test.percent, dl.String(), dl.PercentString())
+		}
+		if f := dl.Format(); f != test.delta+", "+test.percent {
+			t.Errorf("bad formatted delta on %s: %s", test.field, f)
+		}
+	}
+	if d.Param["Name"].Delta != nil {
+		t.Errorf("unexpected delta on a string: %v", d.Param["Name"].Delta)
+	}
+	if d.Param["Secret"].Delta != nil {
+		t.Errorf("delta of a sensitive field not redacted: %v", d.Param["Secret"].Delta)
+	}
+}
+
+func TestDeltaFromZero(t *testing.T) {
+	d, err := checkDiff2(quota{N: "Q"}, quota{N: "Q", Count: 3})
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	dl := d.Param["Count"].Delta
+	if dl.String() != "+3" || dl.PercentString() != "" || dl.Format() != "+3" {
+		t.Errorf("bad delta from zero: %s %q", dl.String(), dl.PercentString())
+	}
+}
+
+func TestDeltaApply(t *testing.T) {
+	d, err := checkDiff2(quota{N: "Q", Count: 10, Free: 5, Timeout: time.Second}, quota{N: "Q", Count: 15, Free: 3, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+
+	//the increments apply on top of values changed concurrently
+	testcase := []struct {
+		field    string
+		value    interface{}
+		expected interface{}
+	}{
+		{field: "Count", value: 12, expected: 17},
+		{field: "Free", value: uint(7), expected: uint(5)},
+		{field: "Timeout", value: time.Minute, expected: time.Minute + time.Second},
+	}
+	for _, test := range testcase {
+		r, err := d.Param[test.field].Delta.Apply(test.value)
+		if err != nil {
+			t.Errorf("increment of %s failed with error %v", test.field, err)
+			continue
+		}
+		if r != test.expected {
+			t.Errorf("bad increment of %s. Expected %v, got %v", test.field, test.expected, r)
+		}
+	}
+
+	if _, err := d.Param["Count"].Delta.Apply(int64(1)); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expected ErrTypeMismatch, got %v", err)
+	}
+}
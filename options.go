@@ -0,0 +1,69 @@
+package api
+
+import "reflect"
+
+//Option configures a diff run
+type Option func(*options)
+
+//options collects the settings applied to a diff run. Zero values are the defaults, and mean no limit.
+type options struct {
+	maxDepth             int
+	maxChanges           int
+	maxCompositionSize   int
+	collectErrors        bool
+	keyFuncs             map[reflect.Type]func(interface{}) []interface{}
+	renameThreshold      float64
+	bestMatch            bool
+	detectMoves          bool
+	transitions          map[reflect.Type]map[interface{}][]interface{}
+	redactedPaths        []string
+	redactionHash        bool
+	redactionSalt        []byte
+	nilDistinctFromEmpty bool
+	nilEqualsZero        bool
+	tolerance            tolerance
+	lineDiffThreshold    int
+	wordDiff             bool
+	charDiff             bool
+	binaryText           bool
+	typeNormalizers      map[reflect.Type]Normalizer
+	pathNormalizers      []pathNormalizer
+	namedNormalizers     map[string]Normalizer
+}
+
+func newOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+//WithMaxDepth limits how deep the diff recurses into nested identified objects
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+//WithMaxChanges limits the number of changes (params, new and deleted items) recorded in the diff
+func WithMaxChanges(n int) Option {
+	return func(o *options) {
+		o.maxChanges = n
+	}
+}
+
+//WithMaxCompositionSize limits the number of elements accepted in each side of a composition
+func WithMaxCompositionSize(n int) Option {
+	return func(o *options) {
+		o.maxCompositionSize = n
+	}
+}
+
+//WithCollectErrors makes the diff record errors with their path and go on with the remaining fields and items,
+//instead of failing on the first one. The partial diff is returned along with the joined errors.
+func WithCollectErrors() Option {
+	return func(o *options) {
+		o.collectErrors = true
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//Violation is a change breaking a change rule
+type Violation struct {
+	Path string
+	Rule string // "immutable", "createOnly", "appendOnly" or "transition"
+	Msg  string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", v.Path, v.Rule, v.Msg)
+}
+
+//ViolationError lists all the violations found in a diff by validateDiff
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e *ViolationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return "change policy violated: " + strings.Join(s, "; ")
+}
+
+//Is makes errors.Is(err, ErrPolicyViolation) true for any ViolationError
+func (e *ViolationError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+//WithTransitions restricts the changes of the values of the enum type t: a value can only change to one of the values
+//listed for it in allowed. Values missing from allowed cannot change.
+//t must be comparable, the diff of a field of another type fails with ErrInvalidOption.
+func WithTransitions(t reflect.Type, allowed map[interface{}][]interface{}) Option {
+	return func(o *options) {
+		if o.transitions == nil {
+			o.transitions = map[reflect.Type]map[interface{}][]interface{}{}
+		}
+		o.transitions[t] = allowed
+	}
+}
+
+//checkRules records on the change of the field in d the rule it breaks, if any, current being the value of the field.
+//It must run before the values are redacted.
+func (df *differ) checkRules(d *diff, fi *fieldInfo, fPath string, current reflect.Value) error {
+	if dc, ok := d.Composition[fi.name]; ok && fi.createOnly && !isEmptyValue(current) {
+		dc.CreateOnly = true
+		d.Composition[fi.name] = dc
+	}
+	dv, ok := d.Param[fi.name]
+	if !ok {
+		return nil
+	}
+	allowed, err := df.allowedTransition(fPath, dv.Current, dv.Proposed)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fi.immutable:
+		dv.Violation = "immutable"
+	case fi.createOnly && !isEmptyValue(reflect.ValueOf(dv.Current)):
+		dv.Violation = "createOnly"
+	case !allowed:
+		dv.Violation = "transition"
+	}
+	d.Param[fi.name] = dv
+	return nil
+}
+
+//allowedTransition reports whether the change from current to proposed is allowed by WithTransitions.
+//It fails for a type whose values cannot be looked up in the table.
+func (df *differ) allowedTransition(fPath string, current, proposed interface{}) (bool, error) {
+	if current == nil {
+		return true, nil
+	}
+	t := reflect.TypeOf(current)
+	allowed, ok := df.opts.transitions[t]
+	if !ok {
+		return true, nil
+	}
+	if !t.Comparable() {
+		return false, newDiffError(fPath, ErrInvalidOption, "transitions of the non comparable type %s", t)
+	}
+	for _, a := range allowed[current] {
+		if a == proposed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//validateDiff checks every change of d against the change rules declared by the diff tags
+//immutable, createOnly and appendOnly, and the tables given by WithTransitions to the diff run.
+//All the violations are returned in a ViolationError, ordered by path.
+func validateDiff(d *diff) error {
+	violations := collectViolations(d, nil)
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+	//a composition changed by several moves, or by a move and other changes, is reported once
+	seen := map[Violation]bool{}
+	unique := violations[:0]
+	for _, v := range violations {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return &ViolationError{Violations: unique}
+}
+
+func collectViolations(d *diff, violations []Violation) []Violation {
+	for name, dv := range d.Param {
+		if dv.Violation != "" {
+			violations = append(violations, Violation{
+				Path: fieldPath(d.Path, name),
+				Rule: dv.Violation,
+				Msg:  fmt.Sprintf("%v -> %v", dv.Current, dv.Proposed),
+			})
+		}
+	}
+	for name, dc := range d.Composition {
+		fPath := fieldPath(d.Path, name)
+		if dc.Immutable {
+			violations = append(violations, Violation{Path: fPath, Rule: "immutable", Msg: "composition changed"})
+		}
+		if dc.CreateOnly {
+			violations = append(violations, Violation{Path: fPath, Rule: "createOnly", Msg: "composition changed"})
+		}
+		if dc.AppendOnly {
+			for _, o := range dc.Deleted {
+				violations = append(violations, Violation{Path: itemPath(fPath, identifierOf(o)), Rule: "appendOnly", Msg: "item deleted"})
+			}
+			for _, tc := range dc.TypeChanged {
+				violations = append(violations, Violation{Path: itemPath(fPath, tc.ID), Rule: "appendOnly", Msg: "item replaced"})
+			}
+			for _, r := range dc.Renamed {
+				violations = append(violations, Violation{Path: itemPath(fPath, r.OldID), Rule: "appendOnly", Msg: "item renamed to " + r.NewID})
+			}
+		}
+		for i := range dc.Modified {
+			violations = collectViolations(&dc.Modified[i], violations)
+		}
+		for i := range dc.Renamed {
+			violations = collectViolations(&dc.Renamed[i].Diff, violations)
+		}
+	}
+	for _, m := range d.Moved {
+		//the item left and joined compositions pruned of it
+		if m.FromImmutable {
+			violations = append(violations, Violation{Path: m.compositionPath(m.From), Rule: "immutable", Msg: "composition changed"})
+		}
+		if m.FromAppendOnly {
+			violations = append(violations, Violation{Path: m.From, Rule: "appendOnly", Msg: "item moved to " + m.To})
+		}
+		if m.FromCreateOnly {
+			violations = append(violations, Violation{Path: m.compositionPath(m.From), Rule: "createOnly", Msg: "composition changed"})
+		}
+		if m.ToImmutable {
+			violations = append(violations, Violation{Path: m.compositionPath(m.To), Rule: "immutable", Msg: "composition changed"})
+		}
+		if m.ToCreateOnly {
+			violations = append(violations, Violation{Path: m.compositionPath(m.To), Rule: "createOnly", Msg: "composition changed"})
+		}
+		if m.Diff != nil {
+			violations = collectViolations(m.Diff, violations)
+		}
+	}
+	return violations
+}
+
+//identifierOf returns the identifier of a New or Deleted item
+func identifierOf(item interface{}) string {
+	if p, ok := item.(HasIdentifier); ok {
+		return p.ID()
+	}
+	return fmt.Sprint(item)
+}
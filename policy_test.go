@@ -0,0 +1,228 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type phase string
+
+type volume struct {
+	Name         string `diff:"id"`
+	StorageClass string `diff:"immutable"`
+	CreatedAt    string `diff:"createOnly"`
+	Phase        phase
+	Token        string  `diff:"createOnly,sensitive"`
+	Snapshots    []PathI `diff:"appendOnly"`
+	Mounts       []PathI
+	Parts        []*volume `diff:"appendOnly"`
+}
+
+type cluster struct {
+	N       string
+	Volumes []volume
+}
+
+func (c cluster) ID() string {
+	return c.N
+}
+
+func TestValidateDiff(t *testing.T) {
+
+	transitions := WithTransitions(reflect.TypeOf(phase("")), map[interface{}][]interface{}{
+		phase("Pending"): {phase("Bound")},
+		phase("Bound"):   {phase("Released")},
+	})
+
+	testcase := []struct {
+		name       string
+		current    volume
+		proposed   volume
+		violations []string
+	}{
+		{
+			name:     "allowed changes",
+			current:  volume{Name: "v", Phase: "Pending", Snapshots: []PathI{"s1"}, Mounts: []PathI{"m1"}},
+			proposed: volume{Name: "v", CreatedAt: "today", Phase: "Bound", Token: "t", Snapshots: []PathI{"s1", "s2"}, Mounts: []PathI{"m2"}},
+		},
+		{
+			name:     "all rules",
+			current:  volume{Name: "v", StorageClass: "ssd", CreatedAt: "today", Phase: "Pending", Token: "t1", Snapshots: []PathI{"s1"}},
+			proposed: volume{Name: "v", StorageClass: "hdd", CreatedAt: "tomorrow", Phase: "Released", Token: "t2", Snapshots: []PathI{"s2"}},
+			violations: []string{
+				"Volumes[v].CreatedAt: createOnly: today -> tomorrow",
+				"Volumes[v].Phase: transition: Pending -> Released",
+				"Volumes[v].Snapshots[s1]: appendOnly: item deleted",
+				"Volumes[v].StorageClass: immutable: ssd -> hdd",
+				"Volumes[v].Token: createOnly: [REDACTED] -> [REDACTED]",
+			},
+		},
+		{
+			name:       "nested",
+			current:    volume{Name: "v", Parts: []*volume{{Name: "p", StorageClass: "ssd"}}},
+			proposed:   volume{Name: "v", Parts: []*volume{{Name: "p", StorageClass: "hdd"}}},
+			violations: []string{"Volumes[v].Parts[p].StorageClass: immutable: ssd -> hdd"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(cluster{N: "c", Volumes: []volume{test.current}}, cluster{N: "c", Volumes: []volume{test.proposed}}, transitions)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		err = validateDiff(d)
+		if len(test.violations) == 0 {
+			if err != nil {
+				t.Errorf("Test %s, unexpected violations %v", test.name, err)
+			}
+			continue
+		}
+		var ve *ViolationError
+		if !errors.As(err, &ve) || !errors.Is(err, ErrPolicyViolation) {
+			t.Errorf("Test %s, expected a ViolationError, got %v", test.name, err)
+			continue
+		}
+		violations := []string{}
+		for _, v := range ve.Violations {
+			violations = append(violations, v.String())
+		}
+		if !reflect.DeepEqual(violations, test.violations) {
+			t.Errorf("Test %s did not give expected violations:\nExpected:\n%v\nGot:\n%v\n", test.name, test.violations, violations)
+		}
+	}
+}
+
+type zones []string
+
+type placement struct {
+	N     string
+	Zones zones
+}
+
+func (p placement) ID() string {
+	return p.N
+}
+
+func TestTransitionsNotComparable(t *testing.T) {
+	transitions := WithTransitions(reflect.TypeOf(zones{}), map[interface{}][]interface{}{})
+	_, err := checkDiff2(placement{N: "p", Zones: zones{"a"}}, placement{N: "p", Zones: zones{"b"}}, transitions)
+	var de *DiffError
+	if !errors.As(err, &de) || !errors.Is(err, ErrInvalidOption) || de.Path != "Zones" {
+		t.Errorf("Expected an ErrInvalidOption on Zones, got %v", err)
+	}
+}
+
+type shelf struct {
+	N      string
+	Locked []PathI `diff:"immutable"`
+	Open   []PathI
+	Log    []PathI `diff:"appendOnly"`
+}
+
+func (s shelf) ID() string {
+	return s.N
+}
+
+func TestValidateDiffMoves(t *testing.T) {
+	testcase := []struct {
+		name       string
+		current    shelf
+		proposed   shelf
+		violations []string
+	}{
+		{
+			name:       "moved out of an append only composition",
+			current:    shelf{N: "s", Log: []PathI{"a"}},
+			proposed:   shelf{N: "s", Open: []PathI{"a"}},
+			violations: []string{"Log[a]: appendOnly: item moved to Open[a]"},
+		},
+		{
+			name:       "moved between immutable compositions",
+			current:    shelf{N: "s", Locked: []PathI{"a", "b"}},
+			proposed:   shelf{N: "s", Open: []PathI{"a", "b"}},
+			violations: []string{"Locked: immutable: composition changed"},
+		},
+		{
+			name:       "moved into an immutable composition",
+			current:    shelf{N: "s", Open: []PathI{"a"}},
+			proposed:   shelf{N: "s", Locked: []PathI{"a"}},
+			violations: []string{"Locked: immutable: composition changed"},
+		},
+	}
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, WithMoveDetection())
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		var ve *ViolationError
+		if !errors.As(validateDiff(d), &ve) {
+			t.Errorf("Test %s, expected a ViolationError", test.name)
+			continue
+		}
+		violations := []string{}
+		for _, v := range ve.Violations {
+			violations = append(violations, v.String())
+		}
+		if !reflect.DeepEqual(violations, test.violations) {
+			t.Errorf("Test %s did not give expected violations:\nExpected:\n%v\nGot:\n%v\n", test.name, test.violations, violations)
+		}
+	}
+}
+
+type claim struct {
+	N     string
+	Ref   HasIdentifier `diff:"createOnly"`
+	Sub   *innerStruct  `diff:"createOnly"`
+	Items []PathI       `diff:"createOnly"`
+}
+
+func (c claim) ID() string {
+	return c.N
+}
+
+func TestValidateDiffCreateOnlyObjects(t *testing.T) {
+	testcase := []struct {
+		name       string
+		current    claim
+		proposed   claim
+		violations []string
+	}{
+		{
+			name:     "set",
+			current:  claim{N: "c"},
+			proposed: claim{N: "c", Ref: PathI("r"), Sub: &innerStruct{A: "s"}, Items: []PathI{"i"}},
+		},
+		{
+			name:       "replaced",
+			current:    claim{N: "c", Ref: PathI("r"), Sub: &innerStruct{A: "s"}, Items: []PathI{"i"}},
+			proposed:   claim{N: "c", Ref: PathI("q"), Sub: &innerStruct{A: "t"}, Items: []PathI{"j"}},
+			violations: []string{"Items: createOnly: composition changed", "Ref: createOnly: composition changed", "Sub: createOnly: composition changed"},
+		},
+		{
+			name:       "modified",
+			current:    claim{N: "c", Sub: &innerStruct{A: "s", Data: "a"}},
+			proposed:   claim{N: "c", Sub: &innerStruct{A: "s", Data: "b"}},
+			violations: []string{"Sub: createOnly: composition changed"},
+		},
+	}
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		violations := []string{}
+		var ve *ViolationError
+		if errors.As(validateDiff(d), &ve) {
+			for _, v := range ve.Violations {
+				violations = append(violations, v.String())
+			}
+		}
+		if len(violations) != len(test.violations) || len(violations) > 0 && !reflect.DeepEqual(violations, test.violations) {
+			t.Errorf("Test %s did not give expected violations:\nExpected:\n%v\nGot:\n%v\n", test.name, test.violations, violations)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+//redactedValue replaces the values of the sensitive fields in the diff
+const redactedValue = "[REDACTED]"
+
+//WithRedactedPaths redacts the fields whose path matches one of patterns, as the fields tagged diff:"sensitive".
+//Patterns are matched against the whole path of the field, "*" matching any characters but "." and "**" any characters,
+//as in "Users[*].Password" or "**.Token".
+func WithRedactedPaths(patterns ...string) Option {
+	return func(o *options) {
+		o.redactedPaths = append(o.redactedPaths, patterns...)
+	}
+}
+
+//WithRedactionHash replaces the sensitive values with a hash salted with salt instead of a marker,
+//so that a reader can tell whether two redacted values are equal without seeing them.
+func WithRedactionHash(salt []byte) Option {
+	return func(o *options) {
+		o.redactionHash = true
+		o.redactionSalt = salt
+	}
+}
+
+//sensitive reports whether the field at path must be redacted
+func (df *differ) sensitive(fi *fieldInfo, fPath string) bool {
+	if fi.sensitive {
+		return true
+	}
+	for _, p := range df.opts.redactedPaths {
+		if matchPath(p, fPath) {
+			return true
+		}
+	}
+	return false
+}
+
+//matchPath reports whether p matches pattern, "*" matching any characters but "." and "**" any characters
+func matchPath(pattern, p string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] != '*' {
+			if len(p) == 0 || p[0] != pattern[0] {
+				return false
+			}
+			pattern, p = pattern[1:], p[1:]
+			continue
+		}
+		crossDots := len(pattern) > 1 && pattern[1] == '*'
+		if crossDots {
+			pattern = pattern[2:]
+		} else {
+			pattern = pattern[1:]
+		}
+		for i := 0; i <= len(p); i++ {
+			if matchPath(pattern, p[i:]) {
+				return true
+			}
+			if i < len(p) && p[i] == '.' && !crossDots {
+				return false
+			}
+		}
+		return false
+	}
+	return len(p) == 0
+}
+
+//redacted returns the replacement of a sensitive value
+func (df *differ) redacted(v interface{}) string {
+	if !df.opts.redactionHash {
+		return redactedValue
+	}
+	h := sha256.New()
+	h.Write(df.opts.redactionSalt)
+	fmt.Fprint(h, v)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+//redactParam replaces the values of the change of a sensitive field recorded in d
+func (df *differ) redactParam(d *diff, fi *fieldInfo, fPath string) {
+	dv, ok := d.Param[fi.name]
+	if !ok || !df.sensitive(fi, fPath) {
+		return
+	}
+	dv.Current, dv.Proposed, dv.Redacted = df.redacted(dv.Current), df.redacted(dv.Proposed), true
+	dv.Delta, dv.Text, dv.Binary = nil, nil, nil
+	d.Param[fi.name] = dv
+}
+
+//redactObject returns a copy of the object at path in which the sensitive fields are redacted,
+//or the object itself if it holds none. String fields get the redacted value, other fields are zeroed.
+func (df *differ) redactObject(objectPath string, object interface{}) interface{} {
+	if object == nil {
+		return nil
+	}
+	if k, ok := object.(keyed); ok {
+		k.Value = df.redactObject(objectPath, k.Value)
+		return k
+	}
+	if len(df.opts.redactedPaths) == 0 && !hasSensitive(reflect.TypeOf(object)) {
+		return object
+	}
+	v := reflect.ValueOf(object)
+	if !df.holdsSensitive(objectPath, v) {
+		return object
+	}
+	return df.redactValue(objectPath, v).Interface()
+}
+
+//holdsSensitive reports whether the value at vPath holds a field to redact
+func (df *differ) holdsSensitive(vPath string, v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil() && df.holdsSensitive(vPath, v.Elem())
+	case reflect.Struct:
+		info := structInfoOf(v.Type())
+		for i := range info.fields {
+			fi := &info.fields[i]
+			if !v.Type().Field(fi.index).IsExported() {
+				continue
+			}
+			fPath := fieldPath(vPath, fi.name)
+			if df.sensitive(fi, fPath) || df.holdsSensitive(fPath, v.Field(fi.index)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if df.holdsSensitive(itemPath(vPath, elementID(v.Index(i), i)), v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if df.holdsSensitive(itemPath(vPath, fmt.Sprint(iter.Key().Interface())), iter.Value()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//elementID returns the identifier of the element i of a slice for its path, its index if it has none
+func elementID(e reflect.Value, i int) string {
+	if p, err := identifierFormInterface(e.Interface()); err == nil {
+		return p.ID()
+	}
+	return fmt.Sprint(i)
+}
+
+func (df *differ) redactValue(vPath string, v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(df.redactValue(vPath, v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(df.redactValue(vPath, v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		info := structInfoOf(v.Type())
+		for i := range info.fields {
+			fi := &info.fields[i]
+			f := c.Field(fi.index)
+			if !f.CanSet() {
+				continue
+			}
+			fPath := fieldPath(vPath, fi.name)
+			if !df.sensitive(fi, fPath) {
+				f.Set(df.redactValue(fPath, f))
+			} else if f.Kind() == reflect.String {
+				f.SetString(df.redacted(f.Interface()))
+			} else {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		}
+		return c
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		if v.Kind() == reflect.Slice {
+			c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		}
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(df.redactValue(itemPath(vPath, elementID(v.Index(i), i)), v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), df.redactValue(itemPath(vPath, fmt.Sprint(iter.Key().Interface())), iter.Value()))
+		}
+		return c
+	}
+	return v
+}
+
+//sensitiveTypes caches whether a type holds fields tagged diff:"sensitive"
+var sensitiveTypes sync.Map
+
+//hasSensitive reports whether values of type t can hold fields tagged diff:"sensitive"
+func hasSensitive(t reflect.Type) bool {
+	return hasSensitiveVisit(t, map[reflect.Type]bool{})
+}
+
+func hasSensitiveVisit(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if s, ok := sensitiveTypes.Load(t); ok {
+		return s.(bool)
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	s := false
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		s = hasSensitiveVisit(t.Elem(), visiting)
+	case reflect.Map:
+		s = hasSensitiveVisit(t.Elem(), visiting)
+	case reflect.Interface:
+		//the dynamic type is unknown, the value is walked
+		s = true
+	case reflect.Struct:
+		info := structInfoOf(t)
+		for i := range info.fields {
+			fi := &info.fields[i]
+			if fi.ignore || !t.Field(fi.index).IsExported() {
+				continue
+			}
+			if fi.sensitive || hasSensitiveVisit(t.Field(fi.index).Type, visiting) {
+				s = true
+				break
+			}
+		}
+	}
+	if len(visiting) == 1 {
+		sensitiveTypes.Store(t, s)
+	}
+	delete(visiting, t)
+	return s
+}
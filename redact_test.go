@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type account struct {
+	User     string `diff:"id"`
+	Password string `diff:"sensitive"`
+	Pin      int    `diff:"sensitive"`
+	Token    string
+	Email    string
+}
+
+type directory struct {
+	N        string
+	Accounts []account
+	Admin    account `diff:"value"`
+}
+
+func (d directory) ID() string {
+	return d.N
+}
+
+func TestCheckDiff2Redaction(t *testing.T) {
+	current := directory{N: "D", Accounts: []account{
+		{User: "a", Password: "pa1", Token: "ta1", Email: "a@x"},
+		{User: "b", Password: "pb", Pin: 1234, Token: "tb"},
+	}, Admin: account{User: "root", Password: "r1"}}
+	proposed := directory{N: "D", Accounts: []account{
+		{User: "a", Password: "pa2", Token: "ta2", Email: "a@y"},
+		{User: "c", Password: "pc", Pin: 5678, Token: "tc"},
+	}, Admin: account{User: "root", Password: "r2"}}
+
+	d, err := checkDiff2(current, proposed, WithRedactedPaths("Accounts[*].Token"))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	report := strings.Join(deepReport(d), "\n")
+	for _, secret := range []string{"pa1", "pa2", "ta1", "ta2", "pb", "1234", "tb", "pc", "5678", "tc", "r1", "r2"} {
+		if strings.Contains(report, secret) {
+			t.Errorf("secret %s found in diff:\n%s", secret, report)
+		}
+	}
+	if !strings.Contains(report, "a@x->a@y") {
+		t.Errorf("non sensitive change missing from diff:\n%s", report)
+	}
+	modified := d.Composition["Accounts"].Modified[0]
+	if !modified.Param["Password"].Redacted || !modified.Param["Token"].Redacted || modified.Param["Email"].Redacted {
+		t.Errorf("bad redaction flags %v", modified.Param)
+	}
+	if deleted := d.Composition["Accounts"].Deleted[0].(keyed).Value.(account); deleted.User != "b" || deleted.Password != redactedValue || deleted.Pin != 0 {
+		t.Errorf("deleted item not redacted %v", deleted)
+	}
+	if current.Accounts[1].Password != "pb" {
+		t.Errorf("redaction modified the input")
+	}
+}
+
+func TestCheckDiff2RedactionHash(t *testing.T) {
+	current := directory{N: "D", Accounts: []account{{User: "a", Password: "same", Email: "1"}, {User: "b", Password: "old"}}}
+	proposed := directory{N: "D", Accounts: []account{{User: "a", Password: "same", Email: "2"}, {User: "b", Password: "new"}}}
+
+	d, err := checkDiff2(current, proposed, WithRedactionHash([]byte("salt")))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	for _, m := range d.Composition["Accounts"].Modified {
+		if m.ID != "b" {
+			continue
+		}
+		dv := m.Param["Password"]
+		c, p := dv.Current.(string), dv.Proposed.(string)
+		if !strings.HasPrefix(c, "sha256:") || c == p || strings.Contains(c+p, "old") || strings.Contains(c+p, "new") {
+			t.Errorf("bad hashed redaction %s -> %s", c, p)
+		}
+	}
+}
+
+//deepReport is diffReport including the content of the new and deleted items
+func deepReport(d *diff) []string {
+	report := diffReport(d, []string{})
+	for k, v := range d.Composition {
+		for _, n := range append(append([]interface{}{}, v.New...), v.Deleted...) {
+			report = append(report, fmt.Sprintf("%s.%s:%+v", d.ID, k, n))
+		}
+		for i := range v.Modified {
+			report = append(report, deepReport(&v.Modified[i])...)
+		}
+	}
+	return report
+}
+
+func TestMatchPath(t *testing.T) {
+	testcase := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"Users[*].Password", "Users[bob].Password", true},
+		{"Users[*].Password", "Users[bob].Token", false},
+		{"*.Token", "Users[bob].Token", true},
+		{"*.Token", "Groups[g].Users[bob].Token", false},
+		{"**.Token", "Groups[g].Users[bob].Token", true},
+		{"Token", "Token", true},
+		{"Token", "Tokens", false},
+	}
+	for _, tc := range testcase {
+		if matchPath(tc.pattern, tc.path) != tc.match {
+			t.Errorf("matchPath(%q, %q) should be %v", tc.pattern, tc.path, tc.match)
+		}
+	}
+}
+
+type accountBook struct {
+	N      string
+	Active []account
+	Closed []account
+}
+
+func (b accountBook) ID() string {
+	return b.N
+}
+
+func TestCheckDiff2RedactionMoves(t *testing.T) {
+	current := accountBook{N: "B", Active: []account{{User: "a", Password: "pa1"}, {User: "b", Password: "pb"}}}
+	proposed := accountBook{N: "B", Closed: []account{{User: "a", Password: "pa2"}, {User: "c", Password: "pc"}}}
+	d, err := checkDiff2(current, proposed, WithMoveDetection())
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	report := strings.Join(diffReport(d, []string{}), "\n")
+	if strings.Contains(report, "pa1") || strings.Contains(report, "pa2") || strings.Contains(report, "pb") || strings.Contains(report, "pc") {
+		t.Errorf("secrets not redacted:\n%s", report)
+	}
+	if len(d.Moved) != 1 || d.Moved[0].Diff == nil || !d.Moved[0].Diff.Param["Password"].Redacted {
+		t.Fatalf("changed password of the moved account not reported: %+v", d.Moved)
+	}
+	for _, item := range append(d.Composition["Active"].Deleted, d.Composition["Closed"].New...) {
+		if s := fmt.Sprintf("%+v", item); !strings.Contains(s, redactedValue) {
+			t.Errorf("item not redacted: %s", s)
+		}
+	}
+	if s := fmt.Sprintf("%+v %+v", d.Moved[0].Current, d.Moved[0].Proposed); strings.Contains(s, "pa") {
+		t.Errorf("moved item not redacted: %s", s)
+	}
+}
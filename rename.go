@@ -0,0 +1,94 @@
+package api
+
+import (
+	"reflect"
+	"sort"
+)
+
+//WithRenameDetection pairs the deleted and new items of a composition whose content similarity is at least threshold,
+//and reports them as renamed instead. The similarity is the ratio of fields left unchanged, between 0 and 1.
+func WithRenameDetection(threshold float64) Option {
+	return func(o *options) {
+		o.renameThreshold = threshold
+	}
+}
+
+func (df *differ) addRenamed(d *diff, fieldName, path string, r renamed) error {
+	if err := df.countChange(path); err != nil {
+		return err
+	}
+	dc := d.Composition[fieldName]
+	dc.Renamed = append(dc.Renamed, r)
+	d.Composition[fieldName] = dc
+	return nil
+}
+
+//similarity returns the ratio of fields of current left unchanged in d
+func similarity(current HasIdentifier, d *diff) float64 {
+	fields := 1
+	if t := concreteType(current); t.Kind() == reflect.Struct {
+		fields = structInfoOf(t).compared
+	}
+	if fields == 0 {
+		return 1
+	}
+	s := 1 - float64(len(d.Param)+len(d.Composition))/float64(fields)
+	if s < 0 {
+		return 0
+	}
+	return s
+}
+
+//pairRenames extracts from the new and deleted items of a composition the pairs similar enough to be renames.
+//The most similar pairs are retained first. Nothing is paired when rename detection is off.
+func (df *differ) pairRenames(path string, added, deleted []HasIdentifier, depth int) (remainingAdded, remainingDeleted []HasIdentifier, renames []renamed) {
+	if df.opts.renameThreshold <= 0 || len(added) == 0 || len(deleted) == 0 {
+		return added, deleted, nil
+	}
+
+	type candidate struct {
+		a, d int
+		r    renamed
+	}
+	candidates := []candidate{}
+	trial := df.trial()
+	for i, o := range deleted {
+		for j, n := range added {
+			if concreteType(o) != concreteType(n) {
+				continue
+			}
+			d, err := trial.content(itemPath(path, o.ID()), o, n, depth+1)
+			if err != nil {
+				continue
+			}
+			if s := similarity(o, d); s >= df.opts.renameThreshold {
+				candidates = append(candidates, candidate{a: j, d: i, r: renamed{OldID: o.ID(), NewID: n.ID(), Similarity: s, Diff: *d}})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].r.Similarity > candidates[j].r.Similarity
+	})
+
+	pairedAdded := map[int]bool{}
+	pairedDeleted := map[int]bool{}
+	for _, c := range candidates {
+		if pairedAdded[c.a] || pairedDeleted[c.d] {
+			continue
+		}
+		pairedAdded[c.a] = true
+		pairedDeleted[c.d] = true
+		renames = append(renames, c.r)
+	}
+	for i, n := range added {
+		if !pairedAdded[i] {
+			remainingAdded = append(remainingAdded, n)
+		}
+	}
+	for i, o := range deleted {
+		if !pairedDeleted[i] {
+			remainingDeleted = append(remainingDeleted, o)
+		}
+	}
+	return remainingAdded, remainingDeleted, renames
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCheckDiff2Renames(t *testing.T) {
+
+	testcase := []struct {
+		name     string
+		current  myStruct
+		proposed myStruct
+		opts     []Option
+		report   []string
+	}{
+		{
+			name:     "detection off",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 1, F7: []string{"x"}}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B9", F1: 1, F7: []string{"x"}}}},
+			report:   []string{"A.F3:Deleted=B1", "A.F3:New=B9"},
+		},
+		{
+			name:     "renamed",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 1, F7: []string{"x"}}, {P: "B2", F1: 2}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B9", F1: 1, F7: []string{"x"}}, {P: "B2", F1: 2}}},
+			opts:     []Option{WithRenameDetection(0.9)},
+			report:   []string{"A.F3:Renamed=B1->B9", "B1.P:B1->B9"},
+		},
+		{
+			name:     "renamed and modified",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 1, F7: []string{"x"}}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B9", F1: 2, F7: []string{"x"}}}},
+			opts:     []Option{WithRenameDetection(0.8)},
+			report:   []string{"A.F3:Renamed=B1->B9", "B1.F1:1->2", "B1.P:B1->B9"},
+		},
+		{
+			name:     "below threshold",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 1, F7: []string{"x"}}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B9", F1: 2, F7: []string{"x"}}}},
+			opts:     []Option{WithRenameDetection(0.9)},
+			report:   []string{"A.F3:Deleted=B1", "A.F3:New=B9"},
+		},
+		{
+			name:     "best match",
+			current:  myStruct{P: "A", F3: []myStruct{{P: "B1", F1: 1, F6: []int{1}}}},
+			proposed: myStruct{P: "A", F3: []myStruct{{P: "B8", F1: 2, F6: []int{1}}, {P: "B9", F1: 1, F6: []int{1}}}},
+			opts:     []Option{WithRenameDetection(0.5)},
+			report:   []string{"A.F3:New=B8", "A.F3:Renamed=B1->B9", "B1.P:B1->B9"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed, test.opts...)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
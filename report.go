@@ -0,0 +1,229 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//TextOption configures the text report of a diff
+type TextOption func(*textOptions)
+
+type textOptions struct {
+	color    bool
+	maxValue int
+	width    int
+}
+
+//WithTextColor colors the lines of the text report for a terminal: new in green, deleted in red, modified in yellow
+func WithTextColor() TextOption {
+	return func(o *textOptions) {
+		o.color = true
+	}
+}
+
+//WithTextTruncate cuts the values longer than n characters in the text report
+func WithTextTruncate(n int) TextOption {
+	return func(o *textOptions) {
+		o.maxValue = n
+	}
+}
+
+//WithTextWidth cuts the lines of the text report to n characters
+func WithTextWidth(n int) TextOption {
+	return func(o *textOptions) {
+		o.width = n
+	}
+}
+
+//Markers of the lines of the text report
+const (
+	markNew      byte = '+'
+	markDeleted  byte = '-'
+	markModified byte = '~'
+	markParam    byte = ' '
+)
+
+var markColors = map[byte]string{
+	markNew:      "\x1b[32m",
+	markDeleted:  "\x1b[31m",
+	markModified: "\x1b[33m",
+}
+
+const colorReset = "\x1b[0m"
+
+//textReport writes the text report of a diff tree
+type textReport struct {
+	opts textOptions
+	sb   strings.Builder
+}
+
+//renderText writes d to w as an indented tree. Each object or item is on a line starting with a marker,
+//"+" for new, "-" for deleted and "~" for modified, and its changed fields are under it as "name: old -> new".
+//Fields and compositions are sorted by name, the items of a composition by kind of change.
+func renderText(w io.Writer, d *diff, opts ...TextOption) error {
+	r := &textReport{}
+	for _, opt := range opts {
+		opt(&r.opts)
+	}
+	if !d.Empty() {
+		r.line(0, markModified, d.ID)
+		r.content(1, d)
+	}
+	_, err := io.WriteString(w, r.sb.String())
+	return err
+}
+
+//line writes a line at depth, cut to the report width and colored by its marker
+func (r *textReport) line(depth int, marker byte, text string) {
+	l := strings.Repeat("  ", depth) + string(marker) + " " + text
+	l = truncate(l, r.opts.width)
+	if c, ok := markColors[marker]; ok && r.opts.color {
+		l = c + l + colorReset
+	}
+	r.sb.WriteString(l)
+	r.sb.WriteByte('\n')
+}
+
+//content writes the changes of the object d at depth
+func (r *textReport) content(depth int, d *diff) {
+	for _, m := range d.Moved {
+		r.line(depth, markModified, fmt.Sprintf("%s moved %s -> %s", m.ID, m.From, m.To))
+		if m.Diff != nil {
+			r.content(depth+1, m.Diff)
+		}
+	}
+	for _, name := range sortedKeys(d.Param) {
+		r.param(depth, name, d.Param[name])
+	}
+	for _, name := range sortedCompositions(d) {
+		dc := d.Composition[name]
+		for _, item := range dc.Deleted {
+			r.line(depth, markDeleted, itemPath(name, identifierOf(item))+": "+r.value(item))
+		}
+		for _, item := range dc.New {
+			r.line(depth, markNew, itemPath(name, identifierOf(item))+": "+r.value(item))
+		}
+		for _, tc := range dc.TypeChanged {
+			r.line(depth, markModified, fmt.Sprintf("%s type %s -> %s", itemPath(name, tc.ID), tc.CurrentType, tc.ProposedType))
+		}
+		for _, rn := range dc.Renamed {
+			r.line(depth, markModified, fmt.Sprintf("%s renamed %s (%.0f%%)", itemPath(name, rn.OldID), rn.NewID, rn.Similarity*100))
+			r.content(depth+1, &rn.Diff)
+		}
+		for i := range dc.Modified {
+			r.line(depth, markModified, itemPath(name, dc.Modified[i].ID))
+			r.content(depth+1, &dc.Modified[i])
+		}
+	}
+}
+
+//param writes the change of a field
+func (r *textReport) param(depth int, name string, dv diffValues) {
+	text := name + ": "
+	switch {
+	case dv.Binary != nil:
+		text += dv.Binary.Current.String() + " -> " + dv.Binary.Proposed.String()
+	case dv.Text != nil && len(dv.Text.Words) > 0:
+		text += r.words(dv.Text.Words)
+	case dv.Text != nil:
+		text += "changed lines"
+	default:
+		text += r.value(dv.Current) + " -> " + r.value(dv.Proposed)
+	}
+	if dv.Delta != nil {
+		text += " (" + dv.Delta.Format() + ")"
+	}
+	if dv.Violation != "" {
+		text += " ! " + dv.Violation
+	}
+	r.line(depth, markParam, text)
+	if dv.Text != nil && len(dv.Text.Words) == 0 {
+		for _, l := range strings.Split(dv.Text.Unified(3), "\n") {
+			switch {
+			case l == "":
+			case l[0] == '@':
+				//hunk headers are written as is
+				r.line(depth+1, markParam, l)
+			default:
+				//the line starts with its marker, markNew, markDeleted or markParam
+				r.line(depth+1, l[0], l[1:])
+			}
+		}
+	}
+}
+
+//words writes word edits in the form of git word-diff: [-deleted-]{+inserted+}
+func (r *textReport) words(edits []textEdit) string {
+	var sb strings.Builder
+	for _, e := range edits {
+		switch e.Op {
+		case textDelete:
+			sb.WriteString("[-" + e.Text + "-]")
+		case textInsert:
+			sb.WriteString("{+" + e.Text + "+}")
+		default:
+			sb.WriteString(e.Text)
+		}
+	}
+	return truncate(sb.String(), r.opts.maxValue)
+}
+
+//value formats a value of the diff, cut to the report truncation
+func (r *textReport) value(v interface{}) string {
+	return truncate(formatValue(v), r.opts.maxValue)
+}
+
+//formatValue formats a value of the diff on one line: strings are quoted, byte slices summarized
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "nil"
+	case keyed:
+		return formatValue(t.Value)
+	case string:
+		return strconv.Quote(t)
+	}
+	//byte slices of any named type are summarized
+	if rv := reflect.ValueOf(v); isBytes(rv) {
+		return newBinarySummary(rv.Bytes()).String()
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+//truncate cuts s to n characters ending with "...", n <= 0 meaning no limit
+func truncate(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(runes[:n])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+func sortedKeys(m map[string]diffValues) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//sortedCompositions returns the names of the changed compositions of d, sorted
+func sortedCompositions(d *diff) []string {
+	names := make([]string, 0, len(d.Composition))
+	for name := range d.Composition {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
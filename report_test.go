@@ -0,0 +1,127 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderText(t *testing.T) {
+	current := myStruct{P: "A", F1: 1, F7: []string{"x"}, F3: []myStruct{{P: "B1", F1: 1}, {P: "B2"}}}
+	proposed := myStruct{P: "A", F1: 3, F7: []string{"y"}, F3: []myStruct{{P: "B1", F1: 2}, {P: "B3"}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+
+	testcase := []struct {
+		name     string
+		opts     []TextOption
+		expected []string
+	}{
+		{
+			name: "default",
+			expected: []string{
+				"~ A",
+				"    F1: 1 -> 3 (+2, +200%)",
+				"    F7: [x] -> [y]",
+				"  - F3[B2]: {P:B2 F1:0 F2:0 F3:[] F4:[] F5:[] F6:[] F7:[] F8:<nil> F10:<nil> F11:<nil> F12:{A: Data:} F13:{I:0}}",
+				"  + F3[B3]: {P:B3 F1:0 F2:0 F3:[] F4:[] F5:[] F6:[] F7:[] F8:<nil> F10:<nil> F11:<nil> F12:{A: Data:} F13:{I:0}}",
+				"  ~ F3[B1]",
+				"      F1: 1 -> 2 (+1, +100%)",
+			},
+		},
+		{
+			name: "truncated",
+			opts: []TextOption{WithTextTruncate(10)},
+			expected: []string{
+				"~ A",
+				"    F1: 1 -> 3 (+2, +200%)",
+				"    F7: [x] -> [y]",
+				"  - F3[B2]: {P:B2 F...",
+				"  + F3[B3]: {P:B3 F...",
+				"  ~ F3[B1]",
+				"      F1: 1 -> 2 (+1, +100%)",
+			},
+		},
+		{
+			name: "width",
+			opts: []TextOption{WithTextWidth(12)},
+			expected: []string{
+				"~ A",
+				"    F1: 1...",
+				"    F7: [...",
+				"  - F3[B2...",
+				"  + F3[B3...",
+				"  ~ F3[B1]",
+				"      F1:...",
+			},
+		},
+		{
+			name: "color",
+			opts: []TextOption{WithTextColor(), WithTextTruncate(6)},
+			expected: []string{
+				"\x1b[33m~ A\x1b[0m",
+				"    F1: 1 -> 3 (+2, +200%)",
+				"    F7: [x] -> [y]",
+				"\x1b[31m  - F3[B2]: {P:...\x1b[0m",
+				"\x1b[32m  + F3[B3]: {P:...\x1b[0m",
+				"\x1b[33m  ~ F3[B1]\x1b[0m",
+				"      F1: 1 -> 2 (+1, +100%)",
+			},
+		},
+	}
+
+	for _, test := range testcase {
+		var sb strings.Builder
+		if err := renderText(&sb, d, test.opts...); err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		expected := strings.Join(test.expected, "\n") + "\n"
+		if sb.String() != expected {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%s\nGot:\n%s", test.name, expected, sb.String())
+		}
+	}
+}
+
+func TestRenderTextStrings(t *testing.T) {
+	current := script{N: "S", Body: "alpha\nb\nc\n", Tag: "app:1.2"}
+	proposed := script{N: "S", Body: "alpha\nx\nc\n", Tag: "app:1.3"}
+	d, err := checkDiff2(current, proposed, WithLineDiff(10), WithWordDiff(false))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderText(&sb, d); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := strings.Join([]string{
+		"~ S",
+		"    Body: changed lines",
+		"      @@ -1,3 +1,3 @@",
+		"      alpha",
+		"    - b",
+		"    + x",
+		"      c",
+		"    Tag: app:1.[-2-]{+3+}",
+	}, "\n") + "\n"
+	if sb.String() != expected {
+		t.Errorf("did not give expected report:\nExpected:\n%s\nGot:\n%s", expected, sb.String())
+	}
+
+	sb.Reset()
+	if err := renderText(&sb, &diff{ID: "S"}); err != nil || sb.String() != "" {
+		t.Errorf("expected an empty report, got %q %v", sb.String(), err)
+	}
+}
+
+type certificate []byte
+
+func TestFormatValueBytes(t *testing.T) {
+	expected := newBinarySummary([]byte("pem")).String()
+	for _, v := range []interface{}{[]byte("pem"), certificate("pem"), keyed{Value: certificate("pem")}} {
+		if s := formatValue(v); s != expected {
+			t.Errorf("%T formatted as %q instead of %q", v, s, expected)
+		}
+	}
+}
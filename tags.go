@@ -0,0 +1,260 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//fieldInfo is the diff behavior of a struct field, parsed from its diff tag.
+//The tag holds comma separated options:
+//	ignore          the field is not compared
+//	value           the field is compared as a whole value
+//	composition     the field is compared as identified objects
+//	id              the field identifies the struct in a composition, several fields make a composite key
+//	key=A+B         the items of the composition are identified by their fields A and B
+//	name=display    the field is named display in the diff
+//	immutable       changes of the field are flagged as violations
+//	createOnly      the field can be set when empty, changes afterwards are violations, for objects and compositions as well
+//	appendOnly      items can be added to the composition, removing or replacing them are violations
+//	sensitive       the values of the field are redacted in the diff
+//	omitempty       the zero value and the empty value (nil, empty slice, map or string, pointer to zero) are equal
+//	default=value   the zero value is equal to value, for string, bool, numbers and durations
+//	tolerance=x     numbers that differ by at most x are equal
+//	normalize=a+b   the values are compared once normalized by a then b, see WithNamedNormalizer
+type fieldInfo struct {
+	index       int
+	name        string
+	ignore      bool
+	value       bool
+	composition bool
+	id          bool
+	keys        []string
+	immutable   bool
+	createOnly  bool
+	appendOnly  bool
+	sensitive   bool
+	omitempty   bool
+	def         reflect.Value // invalid if no default
+	tolerance   *tolerance    // nil if no tolerance tag
+	normalize   []string      // names of the normalizers
+	err         error         // set if the tag cannot be parsed
+}
+
+//structInfo holds the parsed diff tags of a struct type
+type structInfo struct {
+	fields   []fieldInfo
+	ids      []int // index of the fields tagged id
+	compared int   // number of fields not ignored
+}
+
+//structInfos caches the structInfo of each struct type met
+var structInfos sync.Map
+
+//structInfoOf returns the parsed diff tags of the struct type t, parsing them once per type
+func structInfoOf(t reflect.Type) *structInfo {
+	if si, ok := structInfos.Load(t); ok {
+		return si.(*structInfo)
+	}
+	si := &structInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		fi := parseField(i, t.Field(i))
+		if fi.id {
+			si.ids = append(si.ids, i)
+		}
+		if !fi.ignore {
+			si.compared++
+		}
+		si.fields = append(si.fields, fi)
+	}
+	actual, _ := structInfos.LoadOrStore(t, si)
+	return actual.(*structInfo)
+}
+
+func parseField(i int, f reflect.StructField) fieldInfo {
+	fi := fieldInfo{index: i, name: f.Name}
+	tag := f.Tag.Get("diff")
+	if tag == "" {
+		return fi
+	}
+	for _, o := range strings.Split(tag, ",") {
+		option, value := o, ""
+		if eq := strings.Index(o, "="); eq >= 0 {
+			option, value = o[:eq], o[eq+1:]
+		}
+		switch option {
+		case "ignore":
+			fi.ignore = true
+		case "value":
+			fi.value = true
+		case "composition":
+			fi.composition = true
+		case "id":
+			if !readableField(f) {
+				fi.err = newDiffError("", ErrInvalidTag, "id tag on unexported field of type %s", f.Type)
+				continue
+			}
+			fi.id = true
+		case "key":
+			if value == "" {
+				fi.err = newDiffError("", ErrInvalidTag, "key tag without fields")
+				continue
+			}
+			fi.keys = strings.Split(value, "+")
+			if err := checkKeys(f.Type, fi.keys); err != nil {
+				fi.err = err
+			}
+		case "name":
+			if value != "" {
+				fi.name = value
+			}
+		case "immutable":
+			fi.immutable = true
+		case "createOnly":
+			fi.createOnly = true
+		case "appendOnly":
+			fi.appendOnly = true
+		case "sensitive":
+			fi.sensitive = true
+		case "omitempty":
+			fi.omitempty = true
+		case "default":
+			def, err := parseDefault(f.Type, value)
+			if err != nil {
+				fi.err = newDiffError("", ErrInvalidTag, "bad default %q: %v", value, err)
+			}
+			fi.def = def
+		case "normalize":
+			if value != "" {
+				fi.normalize = strings.Split(value, "+")
+			}
+		case "tolerance":
+			abs, err := strconv.ParseFloat(value, 64)
+			if err != nil || abs < 0 {
+				fi.err = newDiffError("", ErrInvalidTag, "bad tolerance %q", value)
+				continue
+			}
+			fi.tolerance = &tolerance{abs: abs}
+		default:
+			fi.err = newDiffError("", ErrInvalidTag, "unknown diff tag option %q", o)
+		}
+	}
+	return fi
+}
+
+//checkKeys checks that the items of the composition type t have the key fields keys, and that they can be read.
+//Items of an interface type are checked when they are identified.
+func checkKeys(t reflect.Type, keys []string) error {
+	if k := t.Kind(); k != reflect.Slice && k != reflect.Array {
+		return nil
+	}
+	elem := derefType(t.Elem())
+	if elem.Kind() == reflect.Interface {
+		return nil
+	}
+	if elem.Kind() != reflect.Struct {
+		return newDiffError("", ErrInvalidTag, "key tag on items of type %s", elem)
+	}
+	for _, name := range keys {
+		f, ok := elem.FieldByName(name)
+		if !ok {
+			return newDiffError("", ErrInvalidTag, "key field %s not found in %s", name, elem)
+		}
+		if !readableField(f) {
+			return newDiffError("", ErrInvalidTag, "key field %s is unexported and not of a basic kind", name)
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//parseDefault converts s to a value of type t
+func parseDefault(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch k := t.Kind(); {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetInt(int64(d))
+	case k == reflect.String:
+		v.SetString(s)
+	case k == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetBool(b)
+	case k >= reflect.Int && k <= reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetInt(n)
+	case k >= reflect.Uint && k <= reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetUint(n)
+	case k == reflect.Float32 || k == reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetFloat(f)
+	default:
+		return reflect.Value{}, fmt.Errorf("no default for type %s", t)
+	}
+	return v, nil
+}
+
+//isEmptyValue reports whether v is zero, an empty slice, map or string, or a pointer to such a value
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil() || isEmptyValue(v.Elem())
+	}
+	return v.IsZero()
+}
+
+//equalValues compares the two values of a field, after applying its default and omitempty options
+func (df *differ) equalValues(fi *fieldInfo, c, p reflect.Value) bool {
+	if fi.def.IsValid() {
+		if c.IsZero() {
+			c = fi.def
+		}
+		if p.IsZero() {
+			p = fi.def
+		}
+	}
+	if fi.omitempty && isEmptyValue(c) && isEmptyValue(p) {
+		return true
+	}
+	if fi.tolerance != nil {
+		return df.deepEqual(c, p, *fi.tolerance, map[[2]uintptr]bool{})
+	}
+	return df.equal(c, p)
+}
+
+//flag applies the immutable and appendOnly options of the field to its changes recorded in d
+func (fi *fieldInfo) flag(d *diff) {
+	if dv, ok := d.Param[fi.name]; ok {
+		dv.Immutable = fi.immutable
+		d.Param[fi.name] = dv
+	}
+	if dc, ok := d.Composition[fi.name]; ok {
+		dc.Immutable = fi.immutable
+		dc.AppendOnly = fi.appendOnly
+		d.Composition[fi.name] = dc
+	}
+}
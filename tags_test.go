@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type richTagStruct struct {
+	P        string
+	Label    string            `diff:"name=displayName"`
+	Class    string            `diff:"immutable"`
+	Password string            `diff:"sensitive"`
+	Labels   map[string]string `diff:"omitempty"`
+	Ref      *int              `diff:"omitempty"`
+	Replicas int               `diff:"default=3"`
+	Timeout  time.Duration     `diff:"default=30s"`
+	Children []PathI           `diff:"immutable,name=kids"`
+}
+
+func (p richTagStruct) ID() string {
+	return p.P
+}
+
+type badTagStruct struct {
+	P     string
+	Count int `diff:"default=many"`
+}
+
+func (p badTagStruct) ID() string {
+	return p.P
+}
+
+func TestCheckDiff2RichTags(t *testing.T) {
+	zero := 0
+
+	testcase := []struct {
+		name     string
+		current  richTagStruct
+		proposed richTagStruct
+		report   []string
+	}{
+		{
+			name:     "output name",
+			current:  richTagStruct{P: "A", Label: "a"},
+			proposed: richTagStruct{P: "A", Label: "b"},
+			report:   []string{"A.displayName:a->b"},
+		},
+		{
+			name:     "sensitive",
+			current:  richTagStruct{P: "A", Password: "secret1"},
+			proposed: richTagStruct{P: "A", Password: "secret2"},
+			report:   []string{"A.Password:[REDACTED]->[REDACTED]"},
+		},
+		{
+			name:     "omitempty",
+			current:  richTagStruct{P: "A", Labels: map[string]string{}, Ref: &zero},
+			proposed: richTagStruct{P: "A"},
+			report:   []string{},
+		},
+		{
+			name:     "default",
+			current:  richTagStruct{P: "A", Replicas: 3, Timeout: 30 * time.Second},
+			proposed: richTagStruct{P: "A"},
+			report:   []string{},
+		},
+		{
+			name:     "default changed",
+			current:  richTagStruct{P: "A", Replicas: 3},
+			proposed: richTagStruct{P: "A", Replicas: 4},
+			report:   []string{"A.Replicas:3->4"},
+		},
+		{
+			name:     "composition output name",
+			current:  richTagStruct{P: "A"},
+			proposed: richTagStruct{P: "A", Children: []PathI{"X"}},
+			report:   []string{"A.kids:New=X"},
+		},
+	}
+
+	for _, test := range testcase {
+		d, err := checkDiff2(test.current, test.proposed)
+		if err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		report := diffReport(d, []string{})
+		sort.Strings(report)
+		if !reflect.DeepEqual(report, test.report) {
+			t.Errorf("Test %s did not give expected report:\nExpected:\n%v\nGot:\n%v\n", test.name, test.report, report)
+		}
+	}
+}
+
+func TestCheckDiff2TagFlags(t *testing.T) {
+	d, err := checkDiff2(
+		richTagStruct{P: "A", Class: "ssd", Password: "a"},
+		richTagStruct{P: "A", Class: "hdd", Password: "b", Children: []PathI{"X"}},
+	)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	if !d.Param["Class"].Immutable || d.Param["Password"].Immutable {
+		t.Errorf("bad immutable flags on params: %v", d.Param)
+	}
+	if !d.Param["Password"].Redacted || d.Param["Password"].Current != redactedValue {
+		t.Errorf("sensitive value not redacted: %v", d.Param["Password"])
+	}
+	if !d.Composition["kids"].Immutable {
+		t.Errorf("immutable flag missing on composition: %v", d.Composition["kids"])
+	}
+}
+
+func TestCheckDiff2InvalidTag(t *testing.T) {
+	_, err := checkDiff2(badTagStruct{P: "A"}, badTagStruct{P: "A"})
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+	var de *DiffError
+	if !errors.As(err, &de) || de.Path != "Count" {
+		t.Errorf("expected error at Count, got %v", err)
+	}
+}
+
+type badKeyStruct struct {
+	NoKey   []plainRecord `diff:"key="`
+	Missing []plainRecord `diff:"key=Name+Missing"`
+	Scalar  []string      `diff:"key=Name"`
+}
+
+func TestParseFieldInvalidKey(t *testing.T) {
+	for _, fi := range structInfoOf(reflect.TypeOf(badKeyStruct{})).fields {
+		if !errors.Is(fi.err, ErrInvalidTag) {
+			t.Errorf("expected ErrInvalidTag on %s, got %v", fi.name, fi.err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//Kinds of the changes of the flattened view of a diff
+const (
+	KindChanged     = "changed"     // a field changed, Old and New are its values
+	KindAdded       = "added"       // an item was added to a composition, New is the item
+	KindRemoved     = "removed"     // an item was removed from a composition, Old is the item
+	KindTypeChanged = "typeChanged" // an item was replaced by an item of another type, Old and New are the items
+	KindRenamed     = "renamed"     // an item changed identifier, Old and New are the identifiers
+	KindMoved       = "moved"       // an item moved to another composition, Old and New are its paths
+)
+
+//changeView is the flattened view of a diff given to the templates of renderTemplate
+type changeView struct {
+	ID      string // identifier of the root object
+	Changes []change
+}
+
+//change is a change of the flattened view of a diff
+type change struct {
+	Path      string // location of the change from the root, "F3[B1].F1" for field F1 of the item B1 of F3
+	Kind      string // one of KindChanged, KindAdded, KindRemoved, KindTypeChanged, KindRenamed and KindMoved
+	ID        string // identifier of the object holding the changed field, or of the item
+	Field     string // name of the changed field, or of the composition of the item
+	Old       interface{}
+	New       interface{}
+	Delta     string // numeric change of a field with its percentage, "+5, +50%"
+	Violation string // rule broken by the change, see validateDiff
+}
+
+//templateExecutor is implemented by the templates of text/template and html/template
+type templateExecutor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+//renderTemplate executes t, a text/template or an html/template, on the flattened view of d.
+//The view holds the ID of the root and its Changes, in the order of the diff tree: fields by name,
+//then compositions by name with their removed, added, replaced, renamed and changed items.
+//The helpers of TemplateFuncs are to be added to t before it is parsed.
+func renderTemplate(w io.Writer, t templateExecutor, d *diff) error {
+	return t.Execute(w, changeView{ID: d.ID, Changes: flatten(d, nil)})
+}
+
+//flatten appends to changes the changes of d at any depth
+func flatten(d *diff, changes []change) []change {
+	for _, m := range d.Moved {
+		changes = append(changes, change{Path: m.To, Kind: KindMoved, ID: m.ID, Old: m.From, New: m.To})
+		if m.Diff != nil {
+			changes = flatten(m.Diff, changes)
+		}
+	}
+	for _, name := range sortedKeys(d.Param) {
+		dv := d.Param[name]
+		c := change{Path: fieldPath(d.Path, name), Kind: KindChanged, ID: d.ID, Field: name, Old: dv.Current, New: dv.Proposed, Violation: dv.Violation}
+		if dv.Delta != nil {
+			c.Delta = dv.Delta.Format()
+		}
+		changes = append(changes, c)
+	}
+	for _, name := range sortedCompositions(d) {
+		dc := d.Composition[name]
+		fPath := fieldPath(d.Path, name)
+		for _, item := range dc.Deleted {
+			id := identifierOf(item)
+			changes = append(changes, change{Path: itemPath(fPath, id), Kind: KindRemoved, ID: id, Field: name, Old: item})
+		}
+		for _, item := range dc.New {
+			id := identifierOf(item)
+			changes = append(changes, change{Path: itemPath(fPath, id), Kind: KindAdded, ID: id, Field: name, New: item})
+		}
+		for _, tc := range dc.TypeChanged {
+			changes = append(changes, change{Path: itemPath(fPath, tc.ID), Kind: KindTypeChanged, ID: tc.ID, Field: name, Old: tc.Current, New: tc.Proposed})
+		}
+		for _, r := range dc.Renamed {
+			changes = append(changes, change{Path: itemPath(fPath, r.OldID), Kind: KindRenamed, ID: r.OldID, Field: name, Old: r.OldID, New: r.NewID})
+			changes = flatten(&r.Diff, changes)
+		}
+		for i := range dc.Modified {
+			changes = flatten(&dc.Modified[i], changes)
+		}
+	}
+	return changes
+}
+
+//TemplateFuncs returns the helpers of the templates of renderTemplate, to be given to the Funcs method
+//of a text/template or an html/template:
+//	value v         formats a value on one line, quoting strings and summarizing byte slices
+//	truncate n s    cuts s to n characters ending with "..."
+//	symbol kind     "+" for added, "-" for removed, "~" for the other kinds
+//	join sep list   joins a list of strings
+//	upper s, lower s
+//	plural n word   word followed by "s" unless n is 1
+func TemplateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"value":    formatValue,
+		"truncate": func(n int, s interface{}) string { return truncate(fmt.Sprint(s), n) },
+		"symbol": func(kind string) string {
+			switch kind {
+			case KindAdded:
+				return string(markNew)
+			case KindRemoved:
+				return string(markDeleted)
+			}
+			return string(markModified)
+		},
+		"join":  func(sep string, list []string) string { return strings.Join(list, sep) },
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"plural": func(n int, word string) string {
+			if n == 1 {
+				return word
+			}
+			return word + "s"
+		},
+	}
+}
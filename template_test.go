@@ -0,0 +1,63 @@
+package api
+
+import (
+	htmltemplate "html/template"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFlatten(t *testing.T) {
+	current := myStruct{P: "A", F1: 1, F3: []myStruct{{P: "B1", F1: 1}, {P: "B2"}}}
+	proposed := myStruct{P: "A", F1: 2, F3: []myStruct{{P: "B1", F1: 3}, {P: "B3"}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var report []string
+	for _, c := range flatten(d, nil) {
+		report = append(report, c.Kind+" "+c.Path+" "+c.ID+" "+c.Field+" "+c.Delta)
+	}
+	expected := []string{
+		"changed F1 A F1 +1, +100%",
+		"removed F3[B2] B2 F3 ",
+		"added F3[B3] B3 F3 ",
+		"changed F3[B1].F1 B1 F1 +2, +200%",
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("bad flattened view.\nExpected:\n%v\nGot:\n%v", expected, report)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	current := deployment{N: "web", Image: "nginx:1.2", Pods: []taggedRecord{{Name: "a"}}}
+	proposed := deployment{N: "web", Image: "nginx:<1.3>", Pods: []taggedRecord{{Name: "a"}, {Name: "b"}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+
+	text := template.Must(template.New("chat").Funcs(TemplateFuncs()).Parse(
+		`{{.ID}}: {{len .Changes}} {{plural (len .Changes) "change"}}` +
+			`{{range .Changes}}|{{symbol .Kind}} {{.Path}}{{if eq .Kind "changed"}} {{value .Old}} -> {{truncate 8 (value .New)}}{{end}}{{end}}`))
+	var sb strings.Builder
+	if err := renderTemplate(&sb, text, d); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := `web: 2 changes|~ Image "nginx:1.2" -> "ngin...|+ Pods[b]`
+	if sb.String() != expected {
+		t.Errorf("bad text rendering.\nExpected: %s\nGot:      %s", expected, sb.String())
+	}
+
+	html := htmltemplate.Must(htmltemplate.New("audit").Funcs(TemplateFuncs()).Parse(
+		`<ul>{{range .Changes}}<li class="{{.Kind}}">{{.Path}} {{upper .Kind}} {{value .New}}</li>{{end}}</ul>`))
+	sb.Reset()
+	if err := renderTemplate(&sb, html, d); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected = `<ul><li class="changed">Image CHANGED &#34;nginx:&lt;1.3&gt;&#34;</li><li class="added">Pods[b] ADDED {Name:b Value:0}</li></ul>`
+	if sb.String() != expected {
+		t.Errorf("bad html rendering.\nExpected: %s\nGot:      %s", expected, sb.String())
+	}
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//WithLineDiff gives the string Params of at least threshold bytes, on either side, a line based diff of their values
+func WithLineDiff(threshold int) Option {
+	return func(o *options) {
+		o.lineDiffThreshold = threshold
+	}
+}
+
+//WithWordDiff gives the string Params too short for a line diff a word based diff of their values,
+//or a character based one if chars is set
+func WithWordDiff(chars bool) Option {
+	return func(o *options) {
+		o.wordDiff = true
+		o.charDiff = chars
+	}
+}
+
+//textOp is the operation of a textEdit
+type textOp byte
+
+const (
+	textEqual  textOp = '='
+	textDelete textOp = '-'
+	textInsert textOp = '+'
+)
+
+//textEdit is a piece of text kept, deleted from the current value or inserted in the proposed one
+type textEdit struct {
+	Op   textOp
+	Text string
+}
+
+//textDiff is the diff of the two values of a string Param.
+//Lines holds one edit per line, without its line feed, for long strings.
+//Words holds the edits of runs of words or characters for short strings.
+type textDiff struct {
+	Lines []textEdit
+	Words []textEdit
+}
+
+//textDiff returns the diff of two strings, or byte slices compared as text, as set by the options, or nil if none applies
+func (df *differ) textDiff(current, proposed reflect.Value) *textDiff {
+	if df.isBinaryText(current, proposed) {
+		current, proposed = reflect.ValueOf(string(current.Bytes())), reflect.ValueOf(string(proposed.Bytes()))
+	}
+	if current.Kind() != reflect.String || proposed.Kind() != reflect.String {
+		return nil
+	}
+	c, p := current.String(), proposed.String()
+	switch {
+	case df.opts.lineDiffThreshold > 0 && (len(c) >= df.opts.lineDiffThreshold || len(p) >= df.opts.lineDiffThreshold):
+		return &textDiff{Lines: editScript(splitLines(c), splitLines(p), false)}
+	case df.opts.charDiff:
+		return &textDiff{Words: editScript(splitChars(c), splitChars(p), true)}
+	case df.opts.wordDiff:
+		return &textDiff{Words: editScript(splitWords(c), splitWords(p), true)}
+	}
+	return nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func splitChars(s string) []string {
+	tokens := make([]string, 0, len(s))
+	for _, r := range s {
+		tokens = append(tokens, string(r))
+	}
+	return tokens
+}
+
+//splitWords cuts s into runs of letters and digits, runs of spaces and single other characters
+func splitWords(s string) []string {
+	var tokens []string
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+	start := 0
+	for i, r := range s {
+		if i > start {
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if class(r) == 0 || class(r) != class(prev) {
+				tokens = append(tokens, s[start:i])
+				start = i
+			}
+		}
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+//editScript returns the shortest list of edits turning the tokens a into b, by the Myers algorithm
+//in its linear space variant. With merge consecutive edits of the same operation are joined.
+func editScript(a, b []string, merge bool) []textEdit {
+	edits := shortestEdits(a, b, make([]textEdit, 0, len(a)+len(b)))
+	//the deletions of a change come before its insertions
+	for i := 0; i < len(edits); i++ {
+		j := i
+		for j < len(edits) && edits[j].Op != textEqual {
+			j++
+		}
+		run := edits[i:j]
+		sort.SliceStable(run, func(x, y int) bool { return run[x].Op == textDelete && run[y].Op == textInsert })
+		i = j
+	}
+	if !merge {
+		return edits
+	}
+	var merged []textEdit
+	for _, e := range edits {
+		if l := len(merged) - 1; l >= 0 && merged[l].Op == e.Op {
+			merged[l].Text += e.Text
+			continue
+		}
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+//shortestEdits appends to edits the edits turning a into b. Past their common prefix and suffix,
+//a and b are cut at a point of a shortest edit path and both halves are solved apart.
+func shortestEdits(a, b []string, edits []textEdit) []textEdit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, textEdit{Op: textEqual, Text: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	x, y, ok := middleSnake(a, b)
+	if ok {
+		edits = shortestEdits(a[:x], b[:y], edits)
+		edits = shortestEdits(a[x:], b[y:], edits)
+	} else {
+		for _, t := range a {
+			edits = append(edits, textEdit{Op: textDelete, Text: t})
+		}
+		for _, t := range b {
+			edits = append(edits, textEdit{Op: textInsert, Text: t})
+		}
+	}
+	for _, t := range common {
+		edits = append(edits, textEdit{Op: textEqual, Text: t})
+	}
+	return edits
+}
+
+//middleSnake runs the Myers algorithm from both ends of a and b, which share no prefix nor suffix,
+//and returns the point where the forward and backward paths meet, on a shortest edit path.
+//It fails when a or b is empty, or when the paths do not meet, the edits then replace a by b.
+//Only the furthest reaches of the current step are kept, v[k] being the furthest x on diagonal k = x - y.
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	max := (n + m + 1) / 2
+	offset := max
+	forward, backward := make([]int, 2*max+2), make([]int, 2*max+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	//with an odd delta the paths meet on a forward step, with an even one on a backward step
+	odd := delta%2 != 0
+	//diagonals trimmed at both ends once their paths left the grid
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	furthest := func(v []int, k, d int) int {
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			return v[offset+k+1]
+		}
+		return v[offset+k-1] + 1
+	}
+	for d := 0; d < max; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			x := furthest(forward, k, d)
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				//the backward path on the same diagonal, its x counted from the end
+				if i := offset + delta - k; i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return x, y, true
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			x := furthest(backward, k, d)
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				if i := offset + delta - k; i >= 0 && i < len(forward) && forward[i] != -1 && forward[i] >= n-x {
+					return forward[i], forward[i] - (delta - k), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+//Unified returns the line edits in the unified diff format, with context lines of context around each change
+func (t textDiff) Unified(context int) string {
+	var sb strings.Builder
+	//line numbers of each edit, counted from 1 in the current and proposed values
+	cLines, pLines := make([]int, len(t.Lines)), make([]int, len(t.Lines))
+	c, p := 1, 1
+	for i, e := range t.Lines {
+		cLines[i], pLines[i] = c, p
+		if e.Op != textInsert {
+			c++
+		}
+		if e.Op != textDelete {
+			p++
+		}
+	}
+	for _, h := range hunks(t.Lines, context) {
+		hunk := t.Lines[h[0]:h[1]]
+		cCount, pCount := 0, 0
+		for _, e := range hunk {
+			if e.Op != textInsert {
+				cCount++
+			}
+			if e.Op != textDelete {
+				pCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(cLines[h[0]], cCount), hunkRange(pLines[h[0]], pCount))
+		for _, e := range hunk {
+			writeUnifiedLine(&sb, e)
+		}
+	}
+	return sb.String()
+}
+
+//hunks returns the ranges [start, end) of the edits making the hunks of a unified diff.
+//A hunk goes from context lines before a change to context lines after the last change
+//that is not separated from the next one by more than 2*context equal lines.
+func hunks(edits []textEdit, context int) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(edits); {
+		if edits[i].Op == textEqual {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end, equal := i, 0
+		for j := i; j < len(edits) && equal <= 2*context; j++ {
+			if edits[j].Op == textEqual {
+				equal++
+				continue
+			}
+			end, equal = j, 0
+		}
+		end += context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		ranges = append(ranges, [2]int{start, end})
+		i = end
+	}
+	return ranges
+}
+
+//writeUnifiedLine writes an edit as a line of unified diff, prefixed by "-", "+" or a space
+func writeUnifiedLine(sb *strings.Builder, e textEdit) {
+	op := byte(e.Op)
+	if e.Op == textEqual {
+		op = ' '
+	}
+	sb.WriteByte(op)
+	sb.WriteString(e.Text)
+	sb.WriteByte('\n')
+}
+
+//hunkRange formats the start and length of a hunk, the start of an empty range being the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
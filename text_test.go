@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type script struct {
+	N    string
+	Body string
+	Tag  string
+	Key  string `diff:"sensitive"`
+}
+
+func (s script) ID() string {
+	return s.N
+}
+
+func TestEditScript(t *testing.T) {
+	testcase := []struct {
+		name     string
+		a, b     string
+		expected []textEdit
+	}{
+		{name: "both empty", expected: nil},
+		{name: "insert all", b: "ab", expected: []textEdit{{textInsert, "ab"}}},
+		{name: "delete all", a: "ab", expected: []textEdit{{textDelete, "ab"}}},
+		{name: "replace middle", a: "abc", b: "axc", expected: []textEdit{{textEqual, "a"}, {textDelete, "b"}, {textInsert, "x"}, {textEqual, "c"}}},
+		{name: "common subsequence", a: "abcabba", b: "cbabac", expected: []textEdit{
+			{textDelete, "a"}, {textInsert, "c"}, {textEqual, "b"}, {textDelete, "c"}, {textEqual, "ab"}, {textDelete, "b"}, {textEqual, "a"}, {textInsert, "c"}}},
+	}
+	for _, test := range testcase {
+		edits := editScript(splitChars(test.a), splitChars(test.b), true)
+		if !reflect.DeepEqual(edits, test.expected) {
+			t.Errorf("Test %s gave bad edits. Expected %v, got %v", test.name, test.expected, edits)
+		}
+		//the edits rebuild both texts
+		var a, b strings.Builder
+		for _, e := range edits {
+			if e.Op != textInsert {
+				a.WriteString(e.Text)
+			}
+			if e.Op != textDelete {
+				b.WriteString(e.Text)
+			}
+		}
+		if a.String() != test.a || b.String() != test.b {
+			t.Errorf("Test %s edits do not rebuild the texts: %q %q", test.name, a.String(), b.String())
+		}
+	}
+}
+
+func TestEditScriptShortest(t *testing.T) {
+	//the number of deletions and insertions is the one left by a longest common subsequence
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		s := make([]string, r.Intn(12))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(3)))
+		}
+		return s
+	}
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		lcs := make([][]int, len(a)+1)
+		for x := range lcs {
+			lcs[x] = make([]int, len(b)+1)
+		}
+		for x := len(a) - 1; x >= 0; x-- {
+			for y := len(b) - 1; y >= 0; y-- {
+				switch {
+				case a[x] == b[y]:
+					lcs[x][y] = lcs[x+1][y+1] + 1
+				case lcs[x+1][y] > lcs[x][y+1]:
+					lcs[x][y] = lcs[x+1][y]
+				default:
+					lcs[x][y] = lcs[x][y+1]
+				}
+			}
+		}
+		changes := 0
+		for _, e := range editScript(a, b, false) {
+			if e.Op != textEqual {
+				changes++
+			}
+		}
+		if expected := len(a) + len(b) - 2*lcs[0][0]; changes != expected {
+			t.Errorf("%q -> %q took %d edits instead of %d", a, b, changes, expected)
+		}
+	}
+
+	//a whole rewrite is solved in linear space
+	var current, proposed []string
+	for i := 0; i < 3000; i++ {
+		current = append(current, fmt.Sprint("old ", i))
+		proposed = append(proposed, fmt.Sprint("new ", i))
+	}
+	if edits := editScript(current, proposed, false); len(edits) != 6000 || edits[0].Op != textDelete || edits[5999].Op != textInsert {
+		t.Errorf("bad edits of a rewrite: %d", len(edits))
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	words := splitWords("run  --port=8080 é_t")
+	expected := []string{"run", "  ", "-", "-", "port", "=", "8080", " ", "é_t"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("bad words. Expected %q, got %q", expected, words)
+	}
+}
+
+func TestCheckDiff2LineDiff(t *testing.T) {
+	var current, proposed []string
+	for i := 1; i <= 12; i++ {
+		current = append(current, "line"+string(rune('a'+i)))
+	}
+	proposed = append(proposed, current...)
+	proposed[1] = "changed"
+	proposed = append(proposed[:9], proposed[10:]...)
+
+	d, err := checkDiff2(
+		script{N: "S", Body: strings.Join(current, "\n") + "\n", Tag: "v1", Key: strings.Join(current, "\n")},
+		script{N: "S", Body: strings.Join(proposed, "\n") + "\n", Tag: "v2", Key: strings.Join(proposed, "\n")},
+		WithLineDiff(20),
+	)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	text := d.Param["Body"].Text
+	if text == nil {
+		t.Fatalf("no line diff on Body")
+	}
+	expected := "@@ -1,5 +1,5 @@\n lineb\n-linec\n+changed\n lined\n linee\n linef\n" +
+		"@@ -7,6 +7,5 @@\n lineh\n linei\n linej\n-linek\n linel\n linem\n"
+	if u := text.Unified(3); u != expected {
+		t.Errorf("bad unified diff. Expected:\n%s\nGot:\n%s", expected, u)
+	}
+	if d.Param["Tag"].Text != nil {
+		t.Errorf("unexpected text diff on a short string: %v", d.Param["Tag"].Text)
+	}
+	if d.Param["Key"].Text != nil {
+		t.Errorf("text diff of a sensitive field not redacted: %v", d.Param["Key"].Text)
+	}
+}
+
+func TestCheckDiff2WordDiff(t *testing.T) {
+	current, proposed := script{N: "S", Tag: "image: app:1.2"}, script{N: "S", Tag: "image: app:1.3"}
+
+	d, err := checkDiff2(current, proposed, WithWordDiff(false))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := []textEdit{{textEqual, "image: app:1."}, {textDelete, "2"}, {textInsert, "3"}}
+	if words := d.Param["Tag"].Text.Words; !reflect.DeepEqual(words, expected) {
+		t.Errorf("bad word diff. Expected %v, got %v", expected, words)
+	}
+
+	d, err = checkDiff2(script{N: "S", Tag: "kitten"}, script{N: "S", Tag: "sitting"}, WithWordDiff(true))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected = []textEdit{{textDelete, "k"}, {textInsert, "s"}, {textEqual, "itt"}, {textDelete, "e"}, {textInsert, "i"}, {textEqual, "n"}, {textInsert, "g"}}
+	if chars := d.Param["Tag"].Text.Words; !reflect.DeepEqual(chars, expected) {
+		t.Errorf("bad char diff. Expected %v, got %v", expected, chars)
+	}
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//UnifiedOption configures the unified diff rendering of a diff
+type UnifiedOption func(*unifiedOptions)
+
+type unifiedOptions struct {
+	json     bool
+	context  int
+	diffOpts []Option
+}
+
+//WithUnifiedJSON serializes the objects as JSON instead of YAML in the unified diff
+func WithUnifiedJSON() UnifiedOption {
+	return func(o *unifiedOptions) {
+		o.json = true
+	}
+}
+
+//WithUnifiedContext sets the number of unchanged lines around each change of the unified diff, 3 by default
+func WithUnifiedContext(n int) UnifiedOption {
+	return func(o *unifiedOptions) {
+		o.context = n
+	}
+}
+
+//WithUnifiedDiffOptions gives the unified diff the options of the diff run, so that the objects are serialized
+//with the same identification of the items and the same redaction of the sensitive fields
+func WithUnifiedDiffOptions(opts ...Option) UnifiedOption {
+	return func(o *unifiedOptions) {
+		o.diffOpts = append(o.diffOpts, opts...)
+	}
+}
+
+//renderUnified writes to w the unified diff of current and proposed, serialized as YAML or JSON.
+//Only the lines of the changes found in d are marked "-" or "+", the other differences (ignored fields,
+//values equal within a tolerance or once normalized) are shown by their proposed lines.
+//Each hunk starts with a "@@ path @@" header naming the object holding its changes, "F3[B1]" for the item B1 of F3.
+//The items of compositions are identified as by checkDiff2 with the options of WithUnifiedDiffOptions, or by their index.
+//Sensitive fields are shown redacted.
+func renderUnified(w io.Writer, current, proposed HasIdentifier, d *diff, opts ...UnifiedOption) error {
+	o := unifiedOptions{context: 3}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	df := newDiffer(context.Background(), o.diffOpts)
+	cLines := df.serialize(reflect.ValueOf(current), o.json)
+	pLines := df.serialize(reflect.ValueOf(proposed), o.json)
+
+	//lines are matched on their path and text, so that equal lines of different objects are not paired
+	token := func(l pathLine) string { return l.path + "\x00" + l.text }
+	cTokens, pTokens := make([]string, len(cLines)), make([]string, len(pLines))
+	for i, l := range cLines {
+		cTokens[i] = token(l)
+	}
+	for i, l := range pLines {
+		pTokens[i] = token(l)
+	}
+	changed := changedPaths(d, nil)
+	var edits []textEdit
+	var owners []string
+	for _, e := range editScript(cTokens, pTokens, false) {
+		sep := strings.IndexByte(e.Text, 0)
+		path, text := e.Text[:sep], e.Text[sep+1:]
+		owner, ok := covered(changed, path)
+		if e.Op != textEqual && !ok {
+			if e.Op == textDelete {
+				continue
+			}
+			e.Op = textEqual
+		}
+		edits = append(edits, textEdit{Op: e.Op, Text: text})
+		owners = append(owners, owner)
+	}
+
+	var sb strings.Builder
+	hs := hunks(edits, o.context)
+	if len(hs) > 0 {
+		fmt.Fprintf(&sb, "--- current/%s\n+++ proposed/%s\n", current.ID(), proposed.ID())
+	}
+	for _, h := range hs {
+		//the header names the deepest object holding all the changes of the hunk
+		header, first := "", true
+		for i := h[0]; i < h[1]; i++ {
+			if edits[i].Op == textEqual {
+				continue
+			}
+			if first {
+				header, first = owners[i], false
+				continue
+			}
+			header = commonPath(header, owners[i])
+		}
+		if header == "" {
+			header = d.ID
+		}
+		fmt.Fprintf(&sb, "@@ %s @@\n", header)
+		for _, e := range edits[h[0]:h[1]] {
+			writeUnifiedLine(&sb, e)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+//changedPath is the path of a change, owner being the path of the object holding it:
+//the object of a changed field, or the new, deleted, replaced or moved item itself
+type changedPath struct {
+	path  string
+	owner string
+}
+
+//changedPaths appends to paths the paths of the changes of d
+func changedPaths(d *diff, paths []changedPath) []changedPath {
+	item := func(p string) changedPath {
+		return changedPath{path: p, owner: p}
+	}
+	for _, m := range d.Moved {
+		paths = append(paths, item(m.From), item(m.To))
+		if m.Diff != nil {
+			paths = changedPaths(m.Diff, paths)
+		}
+	}
+	for name := range d.Param {
+		paths = append(paths, changedPath{path: fieldPath(d.Path, name), owner: d.Path})
+	}
+	for name, dc := range d.Composition {
+		fPath := fieldPath(d.Path, name)
+		for _, items := range [][]interface{}{dc.New, dc.Deleted} {
+			for _, i := range items {
+				paths = append(paths, item(itemPath(fPath, identifierOf(i))))
+			}
+		}
+		for _, tc := range dc.TypeChanged {
+			paths = append(paths, item(itemPath(fPath, tc.ID)))
+		}
+		for _, r := range dc.Renamed {
+			paths = append(paths, item(itemPath(fPath, r.OldID)), item(itemPath(fPath, r.NewID)))
+		}
+		for i := range dc.Modified {
+			paths = changedPaths(&dc.Modified[i], paths)
+		}
+	}
+	return paths
+}
+
+//covered returns the owner of the change that path is in, if any
+func covered(changed []changedPath, path string) (string, bool) {
+	for _, c := range changed {
+		if isInPath(path, c.path) {
+			return c.owner, true
+		}
+	}
+	return "", false
+}
+
+//isInPath reports whether p is the path prefix or the path of a field or item under prefix
+func isInPath(p, prefix string) bool {
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	return len(p) == len(prefix) || prefix == "" || p[len(prefix)] == '.' || p[len(prefix)] == '['
+}
+
+//commonPath returns the longest path that a and b are both in
+func commonPath(a, b string) string {
+	for !isInPath(b, a) {
+		i := strings.LastIndexAny(a, ".[")
+		if i < 0 {
+			return ""
+		}
+		a = a[:i]
+	}
+	return a
+}
+
+//pathLine is a line of a serialized object, with the path of the field or item it belongs to
+type pathLine struct {
+	path string
+	text string
+}
+
+//node is a serialized value: a scalar, an object of named fields or a list of items
+type node struct {
+	path   string
+	scalar interface{} // value of a scalar, nil for null
+	fields []namedNode // fields of an object, or items of a list
+	object bool
+	list   bool
+}
+
+type namedNode struct {
+	name string
+	node node
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+//serialize returns the lines of v as YAML, or JSON
+func (df *differ) serialize(v reflect.Value, asJSON bool) []pathLine {
+	n := df.node(v, "", nil)
+	if asJSON {
+		return jsonLines(n, "", "")
+	}
+	if !n.object && !n.list {
+		return []pathLine{{n.path, yamlScalar(n)}}
+	}
+	return yamlLines(n)
+}
+
+//node builds the serialized form of the value at path. Struct fields are named, skipped and redacted as by the diff.
+//keys are the fields identifying the items of a slice, given by the diff:"key=..." tag of its field.
+func (df *differ) node(v reflect.Value, path string, keys []string) node {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return node{path: path}
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return node{path: path}
+	}
+	t := v.Type()
+	switch {
+	case t.Implements(textMarshalerType) && v.CanInterface():
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err == nil {
+			return node{path: path, scalar: string(b)}
+		}
+	case t.Implements(stringerType) && v.Kind() != reflect.Struct && v.CanInterface():
+		return node{path: path, scalar: v.Interface().(fmt.Stringer).String()}
+	}
+	n := node{path: path}
+	switch v.Kind() {
+	case reflect.Struct:
+		n.object = true
+		for _, fi := range structInfoOf(t).fields {
+			if fi.ignore || t.Field(fi.index).PkgPath != "" {
+				continue
+			}
+			fPath := fieldPath(path, fi.name)
+			if df.sensitive(&fi, fPath) {
+				n.fields = append(n.fields, namedNode{fi.name, node{path: fPath, scalar: df.redacted(v.Field(fi.index).Interface())}})
+				continue
+			}
+			n.fields = append(n.fields, namedNode{fi.name, df.node(v.Field(fi.index), fPath, fi.keys)})
+		}
+	case reflect.Map:
+		n.object = true
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			name := fmt.Sprint(k)
+			n.fields = append(n.fields, namedNode{name, df.node(v.MapIndex(k), itemPath(path, name), nil)})
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return node{path: path, scalar: formatValue(v.Bytes())}
+		}
+		n.list = true
+		for i := 0; i < v.Len(); i++ {
+			id := strconv.Itoa(i)
+			if h, ok := df.identify(v.Index(i), keys); ok {
+				id = h.ID()
+			}
+			n.fields = append(n.fields, namedNode{"", df.node(v.Index(i), itemPath(path, id), nil)})
+		}
+	default:
+		if v.CanInterface() {
+			n.scalar = v.Interface()
+		}
+	}
+	return n
+}
+
+func (n node) empty() bool {
+	return (n.object || n.list) && len(n.fields) == 0
+}
+
+//yamlLines returns the block lines of an object or a list
+func yamlLines(n node) []pathLine {
+	var lines []pathLine
+	for _, f := range n.fields {
+		c := f.node
+		inline := !c.object && !c.list || c.empty()
+		switch {
+		case n.list && inline:
+			lines = append(lines, pathLine{c.path, "- " + yamlScalar(c)})
+		case n.list:
+			for i, l := range yamlLines(c) {
+				prefix := "  "
+				if i == 0 {
+					prefix = "- "
+				}
+				lines = append(lines, pathLine{l.path, prefix + l.text})
+			}
+		case inline:
+			lines = append(lines, pathLine{c.path, yamlKey(f.name) + ": " + yamlScalar(c)})
+		default:
+			lines = append(lines, pathLine{c.path, yamlKey(f.name) + ":"})
+			indent := "  "
+			if c.list {
+				indent = ""
+			}
+			for _, l := range yamlLines(c) {
+				lines = append(lines, pathLine{l.path, indent + l.text})
+			}
+		}
+	}
+	return lines
+}
+
+//yamlPlain matches the strings written without quotes
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./ -]*[A-Za-z0-9_./-]$|^[A-Za-z_/]$`)
+
+var yamlReserved = map[string]bool{"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "null": true, "y": true, "n": true}
+
+func yamlKey(s string) string {
+	if yamlPlain.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func yamlScalar(n node) string {
+	switch {
+	case n.object && n.empty():
+		return "{}"
+	case n.list && n.empty():
+		return "[]"
+	}
+	switch s := n.scalar.(type) {
+	case nil:
+		return "null"
+	case string:
+		if yamlPlain.MatchString(s) && !yamlReserved[strings.ToLower(s)] {
+			return s
+		}
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(n.scalar)
+}
+
+//jsonLines returns the lines of n, the first one starting with prefix and the last one ending with comma
+func jsonLines(n node, prefix, comma string) []pathLine {
+	if !n.object && !n.list || n.empty() {
+		return []pathLine{{n.path, prefix + jsonScalar(n) + comma}}
+	}
+	open, close := "{", "}"
+	if n.list {
+		open, close = "[", "]"
+	}
+	lines := []pathLine{{n.path, prefix + open}}
+	for i, f := range n.fields {
+		fPrefix, fComma := "", ","
+		if n.object {
+			fPrefix = jsonString(f.name) + ": "
+		}
+		if i == len(n.fields)-1 {
+			fComma = ""
+		}
+		for _, l := range jsonLines(f.node, fPrefix, fComma) {
+			lines = append(lines, pathLine{l.path, "  " + l.text})
+		}
+	}
+	return append(lines, pathLine{n.path, close + comma})
+}
+
+func jsonScalar(n node) string {
+	switch {
+	case n.object && n.empty():
+		return "{}"
+	case n.list && n.empty():
+		return "[]"
+	}
+	b, err := json.Marshal(n.scalar)
+	if err != nil {
+		return jsonString(fmt.Sprint(n.scalar))
+	}
+	return string(b)
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+type deployment struct {
+	N        string
+	Replicas int
+	Image    string
+	Build    int `diff:"ignore"`
+	Ratio    float64
+	Pods     []taggedRecord
+	Labels   map[string]string
+}
+
+func (d deployment) ID() string {
+	return d.N
+}
+
+func TestRenderUnified(t *testing.T) {
+	current := deployment{N: "web", Replicas: 2, Image: "nginx:1.2", Build: 1, Ratio: 0.5,
+		Pods: []taggedRecord{{Name: "a", Value: 1}, {Name: "b", Value: 1}}, Labels: map[string]string{"app": "web"}}
+	proposed := deployment{N: "web", Replicas: 2, Image: "nginx:1.3", Build: 2, Ratio: 0.5001,
+		Pods: []taggedRecord{{Name: "a", Value: 2}, {Name: "c", Value: 1}}, Labels: map[string]string{"app": "web"}}
+	d, err := checkDiff2(current, proposed, WithTolerance(0.01, 0))
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+
+	testcase := []struct {
+		name     string
+		opts     []UnifiedOption
+		expected []string
+	}{
+		{
+			name: "yaml",
+			opts: []UnifiedOption{WithUnifiedContext(1)},
+			expected: []string{
+				"--- current/web",
+				"+++ proposed/web",
+				"@@ web @@",
+				" Replicas: 2",
+				`-Image: "nginx:1.2"`,
+				`+Image: "nginx:1.3"`,
+				" Ratio: 0.5001",
+				"@@ Pods @@",
+				" - Name: a",
+				"-  Value: 1",
+				"-- Name: b",
+				"-  Value: 1",
+				"+  Value: 2",
+				"+- Name: c",
+				"+  Value: 1",
+				" Labels:",
+			},
+		},
+		{
+			name: "json",
+			opts: []UnifiedOption{WithUnifiedJSON(), WithUnifiedContext(0)},
+			expected: []string{
+				"--- current/web",
+				"+++ proposed/web",
+				"@@ web @@",
+				`-  "Image": "nginx:1.2",`,
+				`+  "Image": "nginx:1.3",`,
+				"@@ Pods[a] @@",
+				`-      "Value": 1`,
+				`+      "Value": 2`,
+				"@@ Pods @@",
+				"-    {",
+				`-      "Name": "b",`,
+				`-      "Value": 1`,
+				"-    }",
+				"+    {",
+				`+      "Name": "c",`,
+				`+      "Value": 1`,
+				"+    }",
+			},
+		},
+	}
+
+	for _, test := range testcase {
+		var sb strings.Builder
+		if err := renderUnified(&sb, current, proposed, d, test.opts...); err != nil {
+			t.Errorf("Test %s failed with error %v", test.name, err)
+			continue
+		}
+		expected := strings.Join(test.expected, "\n") + "\n"
+		if sb.String() != expected {
+			t.Errorf("Test %s did not give expected diff:\nExpected:\n%s\nGot:\n%s", test.name, expected, sb.String())
+		}
+	}
+}
+
+func TestRenderUnifiedNoChange(t *testing.T) {
+	current := deployment{N: "web", Build: 1}
+	proposed := deployment{N: "web", Build: 2}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderUnified(&sb, current, proposed, d); err != nil || sb.String() != "" {
+		t.Errorf("expected an empty diff, got %q %v", sb.String(), err)
+	}
+}
+
+func TestCommonPath(t *testing.T) {
+	testcase := []struct {
+		a, b, expected string
+	}{
+		{"F3[B1]", "F3[B1]", "F3[B1]"},
+		{"F3[B1].F1", "F3[B2]", "F3"},
+		{"F3[B1.x]", "F3[B1]", "F3"},
+		{"F1", "F3", ""},
+	}
+	for _, test := range testcase {
+		if c := commonPath(test.a, test.b); c != test.expected {
+			t.Errorf("commonPath(%q, %q) = %q, expected %q", test.a, test.b, c, test.expected)
+		}
+	}
+}
+
+func TestRenderUnifiedRedacted(t *testing.T) {
+	current := script{N: "S", Body: "run", Tag: "tag-secret-1", Key: "key-secret-1"}
+	proposed := script{N: "S", Body: "run fast", Tag: "tag-secret-2", Key: "key-secret-2"}
+	for _, diffOpts := range [][]Option{{WithRedactedPaths("Tag")}, {WithRedactedPaths("Tag"), WithRedactionHash([]byte("salt"))}} {
+		d, err := checkDiff2(current, proposed, diffOpts...)
+		if err != nil {
+			t.Fatalf("failed with error %v", err)
+		}
+		for _, opts := range [][]UnifiedOption{{WithUnifiedDiffOptions(diffOpts...)}, {WithUnifiedDiffOptions(diffOpts...), WithUnifiedJSON()}} {
+			var sb strings.Builder
+			if err := renderUnified(&sb, current, proposed, d, opts...); err != nil {
+				t.Fatalf("failed with error %v", err)
+			}
+			if strings.Contains(sb.String(), "secret") || !strings.Contains(sb.String(), "run fast") {
+				t.Errorf("secrets not redacted in diff:\n%s", sb.String())
+			}
+		}
+	}
+}
+
+type inventory struct {
+	N     string
+	Items []plainRecord `diff:"key=Name"`
+}
+
+func (i inventory) ID() string {
+	return i.N
+}
+
+func TestRenderUnifiedKeyTag(t *testing.T) {
+	current := inventory{N: "I", Items: []plainRecord{{Name: "a", Value: 1}, {Name: "b", Value: 1}}}
+	proposed := inventory{N: "I", Items: []plainRecord{{Name: "a", Value: 1}, {Name: "b", Value: 2}}}
+	d, err := checkDiff2(current, proposed)
+	if err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	var sb strings.Builder
+	if err := renderUnified(&sb, current, proposed, d, WithUnifiedContext(0)); err != nil {
+		t.Fatalf("failed with error %v", err)
+	}
+	expected := strings.Join([]string{
+		"--- current/I",
+		"+++ proposed/I",
+		"@@ Items[b] @@",
+		"-  Value: 1",
+		"+  Value: 2",
+	}, "\n") + "\n"
+	if sb.String() != expected {
+		t.Errorf("did not give expected diff:\nExpected:\n%s\nGot:\n%s", expected, sb.String())
+	}
+}